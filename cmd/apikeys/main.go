@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-api/services"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: apikeys [-file path] <command> [arguments]
+
+Commands:
+  create -name <name> [-scopes api,admin]   issue a new key and print it
+  revoke <id>                               disable a key
+  list                                      show all keys
+`
+
+func main() {
+	// The key file defaults to the one the server uses
+	godotenv.Load()
+
+	log.SetFlags(0)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	path := flag.String("file", os.Getenv("CLIENT_KEYS_PATH"), "client key file (defaults to CLIENT_KEYS_PATH)")
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatal("No key file given; set CLIENT_KEYS_PATH or pass -file")
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	store, err := services.OpenClientKeyStore(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args := flag.Args(); args[0] {
+	case "create":
+		create(store, args[1:])
+	case "revoke":
+		revoke(store, args[1:])
+	case "list":
+		list(store)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func create(store *services.ClientKeyStore, args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "who the key is for")
+	scopeList := fs.String("scopes", services.ScopeAPI, "comma separated scopes: api, admin")
+	fs.Parse(args)
+
+	if *name == "" {
+		log.Fatal("create needs -name")
+	}
+	var scopes []string
+	for _, scope := range strings.Split(*scopeList, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope != services.ScopeAPI && scope != services.ScopeAdmin {
+			log.Fatalf("Unknown scope %q; use api or admin", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, info, err := store.Create(*name, scopes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Created key %s for %s with scopes %s\n", info.ID, info.Name, strings.Join(info.Scopes, ","))
+	fmt.Printf("\n  %s\n\n", key)
+	fmt.Println("Store it now: the key cannot be shown again.")
+}
+
+func revoke(store *services.ClientKeyStore, args []string) {
+	if len(args) != 1 {
+		log.Fatal("revoke needs exactly one key ID")
+	}
+	if err := store.Revoke(args[0]); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Revoked key %s\n", args[0])
+}
+
+func list(store *services.ClientKeyStore) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tREVOKED")
+	for _, info := range store.List() {
+		revoked := "-"
+		if info.RevokedAt != nil {
+			revoked = info.RevokedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", info.ID, info.Name, strings.Join(info.Scopes, ","), info.CreatedAt.Format(time.DateTime), revoked)
+	}
+	w.Flush()
+}
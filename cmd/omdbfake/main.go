@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"go-api/omdbfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	fixturesPath := flag.String("fixtures", "", "path to a JSON fixture file (defaults to the bundled fixtures)")
+	apiKey := flag.String("apikey", "", "reject requests whose apikey parameter does not match")
+	flag.Parse()
+
+	fixtures, err := omdbfake.DefaultFixtures()
+	if *fixturesPath != "" {
+		fixtures, err = omdbfake.LoadFixtures(*fixturesPath)
+	}
+	if err != nil {
+		log.Fatal("Failed to load fixtures:", err)
+	}
+
+	handler := omdbfake.NewHandler(fixtures)
+	handler.APIKey = *apiKey
+
+	log.Printf("Fake OMDb API listening on %s (%d movies, %d episodes)", *addr, len(fixtures.Movies), len(fixtures.Episodes))
+	if err := http.ListenAndServe(*addr, handler); err != nil {
+		log.Fatal("Failed to start fake OMDb server:", err)
+	}
+}
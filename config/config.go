@@ -0,0 +1,501 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings read from the environment at startup
+type Config struct {
+	Port string
+
+	// OMDbAPIKeys are the keys upstream calls rotate through, read from the
+	// comma-separated OMDB_API_KEYS or else the single OMDB_API_KEY
+	OMDbAPIKeys []string
+
+	// OMDbKeySelection is round-robin or least-used; a key OMDb rejects is
+	// quarantined for OMDbKeyQuarantine
+	OMDbKeySelection  string
+	OMDbKeyQuarantine time.Duration
+
+	// OMDbBaseURL overrides the public OMDb endpoint when non-empty
+	OMDbBaseURL string
+
+	// OMDbConcurrency bounds the upstream calls a single genre or
+	// recommendation fan-out makes at once
+	OMDbConcurrency int
+
+	// OMDbClientTimeout bounds a single upstream HTTP exchange
+	OMDbClientTimeout time.Duration
+
+	Server    Server
+	Timeouts  Timeouts
+	Cache     Cache
+	Store     Store
+	Retry     Retry
+	Breaker   Breaker
+	RateLimit RateLimit
+	Quota     Quota
+	Inbound   Inbound
+	CORS      CORS
+	Log       Log
+
+	// TracingExporter is where spans are sent: none, otlp or stdout
+	TracingExporter string
+
+	// ReadinessProbeInterval is how often /readyz may call OMDb when no
+	// other call has succeeded within it
+	ReadinessProbeInterval time.Duration
+
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For is
+	// believed when working out the client IP; empty trusts none
+	TrustedProxies []string
+
+	// ClientKeysPath is the file of hashed client API keys managed with the
+	// apikeys command. It is required unless AuthDisabled is set.
+	ClientKeysPath string
+
+	// AuthDisabled leaves /api and /admin unauthenticated. It must be set
+	// explicitly so that a missing key file never opens them by accident.
+	AuthDisabled bool
+}
+
+// Log configures the structured logger. Format is json or text.
+type Log struct {
+	Level  slog.Level
+	Format string
+}
+
+// CORS is the cross-origin policy for browser clients. AllowedOrigins are
+// exact origins or patterns such as https://*.example.com; a lone * allows
+// any origin.
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Inbound limits the requests each client may make. Requests are charged
+// their endpoint's cost against a bucket per client IP and, when the client
+// sends an API key, a bucket per key. A PerMinute of zero disables a bucket.
+type Inbound struct {
+	IPPerMinute  float64
+	IPBurst      int
+	KeyPerMinute float64
+	KeyBurst     int
+	Costs        Costs
+}
+
+// Costs are the tokens each endpoint takes from the inbound buckets
+type Costs struct {
+	Movie           int
+	Search          int
+	Episode         int
+	Genre           int
+	Recommendations int
+}
+
+// RateLimit paces outbound OMDb calls with a token bucket. A PerSecond of
+// zero disables it.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// Quota tracks the daily OMDb request allowance. A DailyLimit of zero
+// disables it; an empty Path keeps the count in memory only.
+type Quota struct {
+	DailyLimit int
+	Reserve    int
+	Path       string
+	Location   *time.Location
+}
+
+// Breaker configures the circuit breaker around OMDb. A FailureThreshold of
+// zero disables it.
+type Breaker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+	HalfOpenProbes   int
+}
+
+// Retry controls how transient OMDb failures are retried. MaxAttempts
+// counts the first attempt, so 1 disables retries.
+type Retry struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Cache sizes the in-process OMDb response cache. A MaxEntries of zero
+// disables it.
+type Cache struct {
+	MaxEntries  int
+	DetailsTTL  time.Duration
+	SearchTTL   time.Duration
+	EpisodeTTL  time.Duration
+	NegativeTTL time.Duration
+}
+
+// Store configures the persistent OMDb metadata store. An empty Path
+// disables it.
+type Store struct {
+	Path          string
+	StaleAfter    time.Duration
+	FlushInterval time.Duration
+}
+
+// Server configures the HTTP server. ShutdownTimeout bounds how long
+// in-flight requests are given to finish on SIGINT or SIGTERM.
+type Server struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	ShutdownTimeout   time.Duration
+}
+
+// Timeouts are the per-endpoint deadlines applied to request contexts
+type Timeouts struct {
+	Movie           time.Duration
+	Search          time.Duration
+	Episode         time.Duration
+	Genre           time.Duration
+	Recommendations time.Duration
+}
+
+// longest returns the longest endpoint deadline
+func (t Timeouts) longest() time.Duration {
+	return max(t.Movie, t.Search, t.Episode, t.Genre, t.Recommendations)
+}
+
+// Load reads the configuration from environment variables, applying defaults
+// for everything except the OMDb API keys
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:             getEnv("PORT", "8080"),
+		OMDbAPIKeys:      getList("OMDB_API_KEYS"),
+		OMDbKeySelection: getEnv("OMDB_KEY_SELECTION", "round-robin"),
+		OMDbBaseURL:      os.Getenv("OMDB_BASE_URL"),
+	}
+
+	cfg.Log.Format = getEnv("LOG_FORMAT", "json")
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		return nil, errors.New("LOG_FORMAT must be json or text")
+	}
+	if err := cfg.Log.Level.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return nil, errors.New("LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	cfg.TracingExporter = getEnv("TRACING_EXPORTER", "none")
+	if cfg.TracingExporter != "none" && cfg.TracingExporter != "otlp" && cfg.TracingExporter != "stdout" {
+		return nil, errors.New("TRACING_EXPORTER must be none, otlp or stdout")
+	}
+
+	if len(cfg.OMDbAPIKeys) == 0 {
+		cfg.OMDbAPIKeys = getList("OMDB_API_KEY")
+	}
+	if len(cfg.OMDbAPIKeys) == 0 {
+		return nil, errors.New("OMDB_API_KEY or OMDB_API_KEYS environment variable is required")
+	}
+	if cfg.OMDbKeySelection != "round-robin" && cfg.OMDbKeySelection != "least-used" {
+		return nil, errors.New("OMDB_KEY_SELECTION must be round-robin or least-used")
+	}
+
+	var err error
+	if cfg.OMDbKeyQuarantine, err = getDuration("OMDB_KEY_QUARANTINE", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.OMDbConcurrency, err = getInt("OMDB_CONCURRENCY", 8); err != nil {
+		return nil, err
+	}
+	if cfg.OMDbClientTimeout, err = getDuration("OMDB_CLIENT_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Timeouts.Movie, err = getDuration("MOVIE_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Timeouts.Search, err = getDuration("SEARCH_TIMEOUT", 15*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Timeouts.Episode, err = getDuration("EPISODE_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Timeouts.Genre, err = getDuration("GENRE_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Timeouts.Recommendations, err = getDuration("RECOMMENDATIONS_TIMEOUT", 45*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.Server.ReadHeaderTimeout, err = getDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Server.ReadTimeout, err = getDuration("HTTP_READ_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Server.WriteTimeout, err = getDuration("HTTP_WRITE_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Server.IdleTimeout, err = getDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Server.MaxHeaderBytes, err = getInt("HTTP_MAX_HEADER_BYTES", 1<<20); err != nil {
+		return nil, err
+	}
+	if cfg.Server.ShutdownTimeout, err = getDuration("SHUTDOWN_TIMEOUT", 50*time.Second); err != nil {
+		return nil, err
+	}
+	// A response cut off by the write timeout is lost, so it must outlast
+	// the slowest endpoint deadline
+	if longest := cfg.Timeouts.longest(); cfg.Server.WriteTimeout > 0 && cfg.Server.WriteTimeout <= longest {
+		return nil, fmt.Errorf("HTTP_WRITE_TIMEOUT must be longer than the longest endpoint timeout (%s)", longest)
+	}
+	if cfg.Server.MaxHeaderBytes <= 0 {
+		return nil, errors.New("HTTP_MAX_HEADER_BYTES must be positive")
+	}
+	if cfg.Server.ShutdownTimeout <= 0 {
+		return nil, errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+	// In-flight requests are drained within the shutdown timeout, so it
+	// must leave room for the slowest endpoint deadline too
+	if longest := cfg.Timeouts.longest(); cfg.Server.ShutdownTimeout < longest {
+		return nil, fmt.Errorf("SHUTDOWN_TIMEOUT must be at least the longest endpoint timeout (%s)", longest)
+	}
+
+	if cfg.Cache.MaxEntries, err = getInt("OMDB_CACHE_SIZE", 5000); err != nil {
+		return nil, err
+	}
+	if cfg.Cache.DetailsTTL, err = getDuration("OMDB_CACHE_DETAILS_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Cache.SearchTTL, err = getDuration("OMDB_CACHE_SEARCH_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Cache.EpisodeTTL, err = getDuration("OMDB_CACHE_EPISODE_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Cache.NegativeTTL, err = getDuration("OMDB_CACHE_NEGATIVE_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+
+	cfg.Store.Path = os.Getenv("OMDB_STORE_PATH")
+	if cfg.Store.StaleAfter, err = getDuration("OMDB_STORE_STALE_AFTER", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.Store.FlushInterval, err = getDuration("OMDB_STORE_FLUSH_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.Retry.MaxAttempts, err = getInt("OMDB_RETRY_ATTEMPTS", 3); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.BaseDelay, err = getDuration("OMDB_RETRY_BASE_DELAY", 200*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if cfg.Retry.MaxDelay, err = getDuration("OMDB_RETRY_MAX_DELAY", 2*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.RateLimit.PerSecond, err = getFloat("OMDB_RATE_LIMIT", 5); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.Burst, err = getInt("OMDB_RATE_BURST", 10); err != nil {
+		return nil, err
+	}
+
+	if cfg.Quota.DailyLimit, err = getInt("OMDB_DAILY_QUOTA", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.Quota.Reserve, err = getInt("OMDB_QUOTA_RESERVE", 200); err != nil {
+		return nil, err
+	}
+	cfg.Quota.Path = os.Getenv("OMDB_QUOTA_PATH")
+	if cfg.Quota.Location, err = getLocation("OMDB_QUOTA_TIMEZONE", time.UTC); err != nil {
+		return nil, err
+	}
+
+	if cfg.Inbound.IPPerMinute, err = getFloat("RATE_LIMIT_IP_PER_MINUTE", 60); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.IPBurst, err = getInt("RATE_LIMIT_IP_BURST", 60); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.KeyPerMinute, err = getFloat("RATE_LIMIT_KEY_PER_MINUTE", 300); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.KeyBurst, err = getInt("RATE_LIMIT_KEY_BURST", 150); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.Costs.Movie, err = getInt("RATE_LIMIT_COST_MOVIE", 1); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.Costs.Search, err = getInt("RATE_LIMIT_COST_SEARCH", 2); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.Costs.Episode, err = getInt("RATE_LIMIT_COST_EPISODE", 1); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.Costs.Genre, err = getInt("RATE_LIMIT_COST_GENRE", 10); err != nil {
+		return nil, err
+	}
+	if cfg.Inbound.Costs.Recommendations, err = getInt("RATE_LIMIT_COST_RECOMMENDATIONS", 20); err != nil {
+		return nil, err
+	}
+	// A negative cost would add tokens to a bucket instead of taking them
+	for name, cost := range map[string]int{
+		"RATE_LIMIT_COST_MOVIE":           cfg.Inbound.Costs.Movie,
+		"RATE_LIMIT_COST_SEARCH":          cfg.Inbound.Costs.Search,
+		"RATE_LIMIT_COST_EPISODE":         cfg.Inbound.Costs.Episode,
+		"RATE_LIMIT_COST_GENRE":           cfg.Inbound.Costs.Genre,
+		"RATE_LIMIT_COST_RECOMMENDATIONS": cfg.Inbound.Costs.Recommendations,
+	} {
+		if cost < 0 {
+			return nil, fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if cfg.ReadinessProbeInterval, err = getDuration("READINESS_PROBE_INTERVAL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.ReadinessProbeInterval <= 0 {
+		return nil, errors.New("READINESS_PROBE_INTERVAL must be positive")
+	}
+	cfg.TrustedProxies = getList("TRUSTED_PROXIES")
+	cfg.ClientKeysPath = os.Getenv("CLIENT_KEYS_PATH")
+	if cfg.AuthDisabled, err = getBool("AUTH_DISABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.ClientKeysPath == "" && !cfg.AuthDisabled {
+		return nil, errors.New("CLIENT_KEYS_PATH must be set, or AUTH_DISABLED=true to run without authentication")
+	}
+	if cfg.ClientKeysPath != "" && cfg.AuthDisabled {
+		return nil, errors.New("AUTH_DISABLED=true cannot be combined with CLIENT_KEYS_PATH")
+	}
+
+	cfg.CORS.AllowedOrigins = getList("CORS_ALLOWED_ORIGINS")
+	if len(cfg.CORS.AllowedOrigins) == 0 {
+		cfg.CORS.AllowedOrigins = []string{"*"}
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if _, err := path.Match(origin, ""); err != nil {
+			return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS has a malformed pattern %q", origin)
+		}
+	}
+	cfg.CORS.AllowedHeaders = getList("CORS_ALLOWED_HEADERS")
+	if len(cfg.CORS.AllowedHeaders) == 0 {
+		cfg.CORS.AllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID"}
+	}
+	cfg.CORS.ExposedHeaders = getList("CORS_EXPOSED_HEADERS")
+	if len(cfg.CORS.ExposedHeaders) == 0 {
+		cfg.CORS.ExposedHeaders = []string{"X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"}
+	}
+	if cfg.CORS.AllowCredentials, err = getBool("CORS_ALLOW_CREDENTIALS", false); err != nil {
+		return nil, err
+	}
+	if cfg.CORS.AllowCredentials && slices.Contains(cfg.CORS.AllowedOrigins, "*") {
+		return nil, errors.New("CORS_ALLOW_CREDENTIALS needs CORS_ALLOWED_ORIGINS to list origins rather than *")
+	}
+	if cfg.CORS.MaxAge, err = getDuration("CORS_MAX_AGE", 10*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.Breaker.FailureThreshold, err = getInt("OMDB_BREAKER_FAILURES", 5); err != nil {
+		return nil, err
+	}
+	if cfg.Breaker.OpenDuration, err = getDuration("OMDB_BREAKER_OPEN_DURATION", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Breaker.HalfOpenProbes, err = getInt("OMDB_BREAKER_HALF_OPEN_PROBES", 1); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getList splits a comma-separated variable, dropping empty entries
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false: %w", key, err)
+	}
+	return b, nil
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration such as 30s: %w", key, err)
+	}
+	return d, nil
+}
+
+func getFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", key, err)
+	}
+	return f, nil
+}
+
+func getLocation(key string, fallback *time.Location) (*time.Location, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a time zone such as UTC or America/New_York: %w", key, err)
+	}
+	return loc, nil
+}
+
+func getInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves operational endpoints about the upstream OMDb keys
+type AdminHandler struct {
+	keys  *services.KeyPool
+	quota *services.QuotaTracker
+}
+
+// NewAdminHandler returns an AdminHandler. quota may be nil when quota
+// tracking is disabled.
+func NewAdminHandler(keys *services.KeyPool, quota *services.QuotaTracker) *AdminHandler {
+	return &AdminHandler{
+		keys:  keys,
+		quota: quota,
+	}
+}
+
+// GetKeys handles GET /admin/keys
+func (h *AdminHandler) GetKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"selection": h.keys.Selection(),
+		"keys":      h.keys.Stats(),
+	})
+}
+
+// GetQuota handles GET /admin/quota
+func (h *AdminHandler) GetQuota(c *gin.Context) {
+	if h.quota == nil {
+		writeProblem(c, problemNotFound, "OMDb quota tracking is disabled", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.quota.Status())
+}
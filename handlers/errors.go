@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go-api/middleware"
+	"go-api/models"
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusClientClosedRequest is recorded when the client disconnects before a
+// response could be written
+const statusClientClosedRequest = 499
+
+const problemContentType = "application/problem+json"
+
+// problemType is a category of error response. Its URI is part of the API
+// contract: clients match on it, so existing slugs must never change.
+type problemType struct {
+	slug   string
+	title  string
+	status int
+}
+
+var (
+	problemInvalidRequest      = problemType{"invalid-request", "Invalid request parameters", http.StatusBadRequest}
+	problemQueryTooBroad       = problemType{"query-too-broad", "Query matches too many titles", http.StatusBadRequest}
+	problemNotFound            = problemType{"not-found", "Resource not found", http.StatusNotFound}
+	problemInternal            = problemType{"internal", "Internal server error", http.StatusInternalServerError}
+	problemUpstreamRejected    = problemType{"upstream-credentials-rejected", "Upstream rejected credentials", http.StatusBadGateway}
+	problemUpstreamUnavailable = problemType{"upstream-unavailable", "Upstream unavailable", http.StatusBadGateway}
+	problemUpstreamInvalid     = problemType{"upstream-invalid-response", "Upstream returned an invalid response", http.StatusBadGateway}
+	problemUpstreamQuota       = problemType{"upstream-quota-exhausted", "Upstream quota exhausted", http.StatusServiceUnavailable}
+	problemUpstreamCircuitOpen = problemType{"upstream-circuit-open", "Upstream temporarily disabled", http.StatusServiceUnavailable}
+	problemUpstreamTimeout     = problemType{"upstream-timeout", "Upstream timed out", http.StatusGatewayTimeout}
+)
+
+func (t problemType) uri() string {
+	return "/problems/" + t.slug
+}
+
+// respondError writes the response for an error returned by the movie
+// provider. notFound is the detail sent when the requested resource does
+// not exist. Upstream error text is never sent to the client; anything not
+// recognized is logged and answered with a generic 500.
+func respondError(c *gin.Context, err error, notFound string) {
+	problem, detail := problemInternal, "An unexpected error occurred"
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client is gone; there is nobody to write a body for
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, services.ErrUpstreamTimeout):
+		problem, detail = problemUpstreamTimeout, "The upstream movie service did not respond in time"
+	case errors.Is(err, services.ErrNotFound):
+		problem, detail = problemNotFound, notFound
+	case errors.Is(err, services.ErrTooManyResults):
+		problem, detail = problemQueryTooBroad, "The query matches too many titles; please be more specific"
+	case errors.Is(err, services.ErrCircuitOpen):
+		problem, detail = problemUpstreamCircuitOpen, "The upstream movie service is failing; requests to it are paused, please try again later"
+		setRetryAfter(c, err)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		problem, detail = problemUpstreamQuota, "The upstream movie service quota is exhausted; please try again later"
+		setRetryAfter(c, err)
+	case errors.Is(err, services.ErrInvalidAPIKey):
+		problem, detail = problemUpstreamRejected, "The upstream movie service rejected our credentials"
+	case errors.Is(err, services.ErrUpstreamUnavailable):
+		problem, detail = problemUpstreamUnavailable, "The upstream movie service is unavailable"
+	case errors.Is(err, services.ErrMalformedResponse):
+		problem, detail = problemUpstreamInvalid, "The upstream movie service returned an invalid response"
+	}
+
+	if problem.status >= http.StatusInternalServerError {
+		// The service already scrubs its errors; redact again in case anything slipped through
+		ctx := c.Request.Context()
+		services.Logger(ctx).ErrorContext(ctx, "request failed",
+			"problem", problem.slug,
+			"error", services.RedactURL(err.Error()),
+		)
+	}
+
+	writeProblem(c, problem, detail, nil)
+}
+
+// setRetryAfter tells the client when to come back if the error says
+func setRetryAfter(c *gin.Context, err error) {
+	var upstreamErr *services.UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > 0 {
+		seconds := int(math.Ceil(upstreamErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+// respondInvalid rejects a request whose parameters failed validation
+func respondInvalid(c *gin.Context, invalid []models.InvalidParam) {
+	writeProblem(c, problemInvalidRequest, "One or more request parameters are invalid", invalid)
+}
+
+// RouteNotFound answers requests for paths no route matches
+func RouteNotFound(c *gin.Context) {
+	writeProblem(c, problemNotFound, "No endpoint exists at "+c.Request.URL.Path, nil)
+}
+
+// writeProblem sends an RFC 7807 problem document. The instance member
+// carries the request ID so that a reported error can be found in the logs.
+func writeProblem(c *gin.Context, problem problemType, detail string, invalid []models.InvalidParam) {
+	body := models.Problem{
+		Type:          problem.uri(),
+		Title:         problem.title,
+		Status:        problem.status,
+		Detail:        detail,
+		InvalidParams: invalid,
+	}
+	if id := middleware.GetRequestID(c); id != "" {
+		body.Instance = "urn:request:" + id
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(problem.status, body)
+}
+
+// invalidParam is shorthand for building validation failures
+func invalidParam(name, reason string) models.InvalidParam {
+	return models.InvalidParam{Name: name, Reason: reason}
+}
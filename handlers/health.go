@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildInfo identifies the running build. It is set at build time; see
+// the Building section of the README.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// HealthHandler serves the health, liveness and readiness endpoints
+type HealthHandler struct {
+	movies  services.MovieProvider
+	build   BuildInfo
+	started time.Time
+}
+
+func NewHealthHandler(movies services.MovieProvider, build BuildInfo) *HealthHandler {
+	return &HealthHandler{
+		movies:  movies,
+		build:   build,
+		started: time.Now(),
+	}
+}
+
+// HealthCheck handles GET /health
+//
+// The status is "degraded" while the upstream is being avoided; the API
+// still answers from its cache and store then, so the check does not fail.
+func (h *HealthHandler) HealthCheck(c *gin.Context) {
+	response := gin.H{
+		"status":  "healthy",
+		"message": "Movie API is running",
+		"version": h.build.Version,
+	}
+	if h.build.Commit != "" {
+		response["commit"] = h.build.Commit
+	}
+	if reporter, ok := h.movies.(services.HealthReporter); ok {
+		health := reporter.Health()
+		if health.Degraded() {
+			response["status"] = "degraded"
+		}
+		response["upstream"] = health
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Livez handles GET /livez
+//
+// It only shows that the process is serving requests; restarting it would
+// not fix anything the readiness checks find.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "alive",
+		"version":        h.build.Version,
+		"commit":         h.build.Commit,
+		"uptime_seconds": int(time.Since(h.started).Seconds()),
+	})
+}
+
+// Readyz handles GET /readyz
+//
+// It answers 503 when any check fails, meaning lookups that are not cached
+// cannot be served. Degraded checks leave the instance ready, since it can
+// still answer from its cache and store.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	checks := []services.Check{}
+	if reporter, ok := h.movies.(services.ReadinessReporter); ok {
+		checks = reporter.Readiness(c.Request.Context())
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, check := range checks {
+		switch check.Status {
+		case services.CheckFailed:
+			status, code = "not_ready", http.StatusServiceUnavailable
+		case services.CheckDegraded:
+			if code == http.StatusOK {
+				status = "degraded"
+			}
+		}
+	}
+
+	c.JSON(code, gin.H{
+		"status":  status,
+		"version": h.build.Version,
+		"commit":  h.build.Commit,
+		"checks":  checks,
+	})
+}
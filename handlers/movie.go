@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"regexp"
 	"strconv"
-	"strings"
 
 	"go-api/models"
 	"go-api/services"
@@ -11,58 +13,99 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	// imdbIDPattern matches IMDb title identifiers such as tt0133093
+	imdbIDPattern = regexp.MustCompile(`^tt\d{7,}$`)
+
+	yearPattern = regexp.MustCompile(`^\d{4}$`)
+
+	// validTitleTypes are the values OMDb accepts for its type parameter
+	validTitleTypes = map[string]bool{"movie": true, "series": true, "episode": true}
+)
+
 type MovieHandler struct {
-	omdbService *services.OMDbService
+	movies services.MovieProvider
 }
 
-func NewMovieHandler(omdbService *services.OMDbService) *MovieHandler {
+func NewMovieHandler(movies services.MovieProvider) *MovieHandler {
 	return &MovieHandler{
-		omdbService: omdbService,
+		movies: movies,
 	}
 }
 
-// GetMovieDetails handles GET /api/movie?title=MovieTitle
+// GetMovieDetails handles GET /api/movie?title=MovieTitle&year=1999&type=movie
+//
+// year and type are optional. Without a year, a title shared by several
+// films is answered with 300 Multiple Choices listing the candidates.
 func (h *MovieHandler) GetMovieDetails(c *gin.Context) {
 	title := c.Query("title")
+	query := services.TitleQuery{
+		Title: title,
+		Year:  c.Query("year"),
+		Type:  c.Query("type"),
+	}
+
+	var invalid []models.InvalidParam
 	if title == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "Title query parameter is required",
-			Code:    http.StatusBadRequest,
-		})
+		invalid = append(invalid, invalidParam("title", "is required"))
+	}
+	if query.Year != "" && !yearPattern.MatchString(query.Year) {
+		invalid = append(invalid, invalidParam("year", "must be a four digit year"))
+	}
+	if query.Type != "" && !validTitleTypes[query.Type] {
+		invalid = append(invalid, invalidParam("type", "must be one of movie, series or episode"))
+	}
+	if len(invalid) > 0 {
+		respondInvalid(c, invalid)
 		return
 	}
 
-	movieData, err := h.omdbService.GetMovieByTitle(title)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "Movie not found") {
-			c.JSON(http.StatusNotFound, models.ErrorResponse{
-				Error:   "Not Found",
-				Message: "Movie not found: " + title,
-				Code:    http.StatusNotFound,
+	if query.Year == "" {
+		matches, err := h.movies.TitleMatches(c.Request.Context(), query)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			respondError(c, err, "")
+			return
+		}
+		// Any other search failure just means we cannot tell; fall through to the lookup
+		if len(matches) > 1 {
+			candidates := make([]models.TitleSummary, len(matches))
+			for i, match := range matches {
+				candidates[i] = titleSummary(match)
+			}
+			c.JSON(http.StatusMultipleChoices, models.MultipleChoicesResponse{
+				Error:      "Multiple Choices",
+				Message:    "Several titles match " + title + "; repeat the request with year or use /api/movie/{imdb_id}",
+				Code:       http.StatusMultipleChoices,
+				Candidates: candidates,
 			})
 			return
 		}
+	}
 
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to fetch movie details: " + err.Error(),
-			Code:    http.StatusInternalServerError,
-		})
+	movieData, err := h.movies.FindTitle(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err, "Movie not found: "+title)
 		return
 	}
 
-	response := models.MovieDetailsResponse{
-		Title:    movieData.Title,
-		Year:     movieData.Year,
-		Plot:     movieData.Plot,
-		Country:  movieData.Country,
-		Awards:   movieData.Awards,
-		Director: movieData.Director,
-		Ratings:  movieData.Ratings,
+	c.JSON(http.StatusOK, movieDetailsResponse(movieData))
+}
+
+// GetMovieByID handles GET /api/movie/:imdbID
+func (h *MovieHandler) GetMovieByID(c *gin.Context) {
+	imdbID := c.Param("imdbID")
+	if !imdbIDPattern.MatchString(imdbID) {
+		respondInvalid(c, []models.InvalidParam{invalidParam("imdbID", "must look like tt0133093")})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	movieData, err := h.movies.GetMovieByID(c.Request.Context(), imdbID)
+	if err != nil {
+		respondError(c, err, "Movie not found: "+imdbID)
+		return
+	}
+
+	c.JSON(http.StatusOK, movieDetailsResponse(movieData))
 }
 
 // GetEpisodeDetails handles GET /api/episode?series_title=SeriesTitle&season=1&episode_number=1
@@ -71,51 +114,30 @@ func (h *MovieHandler) GetEpisodeDetails(c *gin.Context) {
 	seasonStr := c.Query("season")
 	episodeStr := c.Query("episode_number")
 
-	if seriesTitle == "" || seasonStr == "" || episodeStr == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "series_title, season, and episode_number query parameters are required",
-			Code:    http.StatusBadRequest,
-		})
-		return
+	var invalid []models.InvalidParam
+	if seriesTitle == "" {
+		invalid = append(invalid, invalidParam("series_title", "is required"))
 	}
-
 	season, err := strconv.Atoi(seasonStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "Season must be a valid integer",
-			Code:    http.StatusBadRequest,
-		})
-		return
+	if seasonStr == "" {
+		invalid = append(invalid, invalidParam("season", "is required"))
+	} else if err != nil {
+		invalid = append(invalid, invalidParam("season", "must be an integer"))
 	}
-
 	episode, err := strconv.Atoi(episodeStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "Episode number must be a valid integer",
-			Code:    http.StatusBadRequest,
-		})
+	if episodeStr == "" {
+		invalid = append(invalid, invalidParam("episode_number", "is required"))
+	} else if err != nil {
+		invalid = append(invalid, invalidParam("episode_number", "must be an integer"))
+	}
+	if len(invalid) > 0 {
+		respondInvalid(c, invalid)
 		return
 	}
 
-	episodeData, err := h.omdbService.GetEpisodeDetails(seriesTitle, season, episode)
+	episodeData, err := h.movies.GetEpisodeDetails(c.Request.Context(), seriesTitle, season, episode)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "Episode not found") {
-			c.JSON(http.StatusNotFound, models.ErrorResponse{
-				Error:   "Not Found",
-				Message: "Episode not found for the given parameters",
-				Code:    http.StatusNotFound,
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to fetch episode details: " + err.Error(),
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, err, "Episode not found for the given parameters")
 		return
 	}
 
@@ -139,37 +161,42 @@ func (h *MovieHandler) GetEpisodeDetails(c *gin.Context) {
 func (h *MovieHandler) GetMoviesByGenre(c *gin.Context) {
 	genre := c.Query("genre")
 	if genre == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "Genre query parameter is required",
-			Code:    http.StatusBadRequest,
-		})
+		respondInvalid(c, []models.InvalidParam{invalidParam("genre", "is required")})
 		return
 	}
 
-	movies, err := h.omdbService.GetMoviesByGenre(genre, 15)
+	results, err := h.movies.GetMoviesByGenre(c.Request.Context(), genre, 15)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to fetch movies by genre: " + err.Error(),
-			Code:    http.StatusInternalServerError,
-		})
+		// Report what was found before the deadline rather than nothing at all
+		if errors.Is(err, context.DeadlineExceeded) && results != nil && len(results.Movies) > 0 {
+			c.JSON(http.StatusGatewayTimeout, models.GenreMoviesResponse{
+				Genre:          genre,
+				Movies:         results.Movies,
+				Count:          len(results.Movies),
+				Partial:        true,
+				FailedRequests: results.FailedRequests,
+			})
+			return
+		}
+		respondError(c, err, "No movies found for genre: "+genre)
 		return
 	}
 
-	if len(movies) == 0 {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "Not Found",
-			Message: "No movies found for genre: " + genre,
-			Code:    http.StatusNotFound,
-		})
+	if len(results.Movies) == 0 {
+		// Nothing found because OMDb kept failing is not the same as nothing existing
+		if results.FailedRequests > 0 {
+			writeProblem(c, problemUpstreamUnavailable, "The upstream movie service is unavailable", nil)
+			return
+		}
+		writeProblem(c, problemNotFound, "No movies found for genre: "+genre, nil)
 		return
 	}
 
 	response := models.GenreMoviesResponse{
-		Genre:  genre,
-		Movies: movies,
-		Count:  len(movies),
+		Genre:          genre,
+		Movies:         results.Movies,
+		Count:          len(results.Movies),
+		FailedRequests: results.FailedRequests,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -179,41 +206,46 @@ func (h *MovieHandler) GetMoviesByGenre(c *gin.Context) {
 func (h *MovieHandler) GetRecommendations(c *gin.Context) {
 	favoriteMovie := c.Query("favorite_movie")
 	if favoriteMovie == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "favorite_movie query parameter is required",
-			Code:    http.StatusBadRequest,
-		})
+		respondInvalid(c, []models.InvalidParam{invalidParam("favorite_movie", "is required")})
 		return
 	}
 
-	recommendations, err := h.omdbService.GetRecommendations(favoriteMovie)
+	recommendations, err := h.movies.GetRecommendations(c.Request.Context(), favoriteMovie)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, models.ErrorResponse{
-				Error:   "Not Found",
-				Message: "Favorite movie not found: " + favoriteMovie,
-				Code:    http.StatusNotFound,
-			})
+		// Report what was found before the deadline rather than nothing at all
+		if errors.Is(err, context.DeadlineExceeded) && recommendations != nil {
+			recommendations.Partial = true
+			c.JSON(http.StatusGatewayTimeout, recommendations)
 			return
 		}
-
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to generate recommendations: " + err.Error(),
-			Code:    http.StatusInternalServerError,
-		})
+		respondError(c, err, "Favorite movie not found: "+favoriteMovie)
 		return
 	}
 
 	c.JSON(http.StatusOK, recommendations)
 }
 
-// HealthCheck handles GET /health
-func (h *MovieHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"message": "Movie API is running",
-		"version": "1.0.0",
-	})
+// movieDetailsResponse trims an OMDb record down to the movie details payload
+func movieDetailsResponse(movieData *models.OMDbResponse) models.MovieDetailsResponse {
+	return models.MovieDetailsResponse{
+		ImdbID:   movieData.ImdbID,
+		Title:    movieData.Title,
+		Year:     movieData.Year,
+		Plot:     movieData.Plot,
+		Country:  movieData.Country,
+		Awards:   movieData.Awards,
+		Director: movieData.Director,
+		Ratings:  movieData.Ratings,
+	}
+}
+
+// titleSummary converts an OMDb search hit to its API representation
+func titleSummary(result models.SearchResult) models.TitleSummary {
+	return models.TitleSummary{
+		Title:  result.Title,
+		Year:   result.Year,
+		ImdbID: result.ImdbID,
+		Type:   result.Type,
+		Poster: result.Poster,
+	}
 }
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-api/models"
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns a router serving the movie endpoints from a fake
+// provider holding a few titles
+func newTestRouter() *gin.Engine {
+	movies := services.NewFakeProvider(
+		&models.OMDbResponse{Title: "The Matrix", Year: "1999", Genre: "Action, Sci-Fi", Director: "Lana Wachowski, Lilly Wachowski", Actors: "Keanu Reeves, Laurence Fishburne", ImdbRating: "8.7", ImdbID: "tt0133093", Type: "movie"},
+		&models.OMDbResponse{Title: "John Wick", Year: "2014", Genre: "Action, Crime, Thriller", Director: "Chad Stahelski", Actors: "Keanu Reeves, Michael Nyqvist", ImdbRating: "7.4", ImdbID: "tt2911666", Type: "movie"},
+		&models.OMDbResponse{Title: "Dune", Year: "1984", Genre: "Action, Adventure, Sci-Fi", Director: "David Lynch", ImdbRating: "6.3", ImdbID: "tt0087182", Type: "movie"},
+		&models.OMDbResponse{Title: "Dune", Year: "2021", Genre: "Action, Adventure, Drama", Director: "Denis Villeneuve", ImdbRating: "8.0", ImdbID: "tt1160419", Type: "movie"},
+		&models.OMDbResponse{Title: "Breaking Bad", Year: "2008–2013", Genre: "Crime, Drama, Thriller", ImdbRating: "9.5", ImdbID: "tt0903747", Type: "series"},
+	)
+	movies.AddEpisode("Breaking Bad", 1, 1, &models.OMDbResponse{Title: "Pilot", Year: "2008", ImdbID: "tt0959621", Type: "episode"})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewMovieHandler(movies)
+	router.GET("/api/movie", h.GetMovieDetails)
+	router.GET("/api/movie/:imdbID", h.GetMovieByID)
+	router.GET("/api/search", h.Search)
+	router.GET("/api/episode", h.GetEpisodeDetails)
+	router.GET("/api/movies/genre", h.GetMoviesByGenre)
+	router.GET("/api/recommendations", h.GetRecommendations)
+	router.NoRoute(RouteNotFound)
+	return router
+}
+
+func get(router *gin.Engine, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestMovieEndpointsSucceed(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []string{
+		"/api/movie?title=the+matrix",
+		"/api/movie?title=Dune&year=2021",
+		"/api/movie/tt0133093",
+		"/api/search?query=dune",
+		"/api/episode?series_title=Breaking+Bad&season=1&episode_number=1",
+		"/api/movies/genre?genre=action",
+		"/api/recommendations?favorite_movie=The+Matrix",
+	}
+	for _, target := range tests {
+		recorder := get(router, target)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200: %s", target, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestMovieDetailsResponses(t *testing.T) {
+	router := newTestRouter()
+
+	recorder := get(router, "/api/movie?title=Dune&year=2021")
+	var details models.MovieDetailsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.ImdbID != "tt1160419" || details.Director != "Denis Villeneuve" {
+		t.Errorf("got %+v, want the 2021 Dune", details)
+	}
+
+	recorder = get(router, "/api/movie?title=Dune")
+	if recorder.Code != http.StatusMultipleChoices {
+		t.Fatalf("ambiguous title: status %d, want 300", recorder.Code)
+	}
+	var choices models.MultipleChoicesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &choices); err != nil {
+		t.Fatal(err)
+	}
+	if len(choices.Candidates) != 2 || choices.Candidates[0].Year != "1984" || choices.Candidates[1].Year != "2021" {
+		t.Errorf("candidates = %+v, want both Dunes", choices.Candidates)
+	}
+}
+
+func TestSearchResponse(t *testing.T) {
+	router := newTestRouter()
+
+	recorder := get(router, "/api/search?query=dune&page_size=1&page=2")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	var page models.SearchPageResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalResults != 2 || page.TotalPages != 2 || len(page.Results) != 1 || page.Results[0].Year != "2021" {
+		t.Errorf("got %+v, want the second of two pages", page)
+	}
+	if page.Prev == "" || page.Next != "" {
+		t.Errorf("prev = %q, next = %q, want only a previous page", page.Prev, page.Next)
+	}
+}
+
+func TestMovieEndpointProblems(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		target  string
+		problem problemType
+		param   string // the invalid parameter reported, if any
+	}{
+		{"/api/movie", problemInvalidRequest, "title"},
+		{"/api/movie?title=Dune&year=84", problemInvalidRequest, "year"},
+		{"/api/movie?title=Dune&type=film", problemInvalidRequest, "type"},
+		{"/api/movie/0133093", problemInvalidRequest, "imdbID"},
+		{"/api/search", problemInvalidRequest, "query"},
+		{"/api/search?query=dune&page=0", problemInvalidRequest, "page"},
+		{"/api/search?query=dune&page_size=51", problemInvalidRequest, "page_size"},
+		{"/api/search?query=dune&page_size=10&page=101", problemInvalidRequest, "page"},
+		{"/api/episode?series_title=Breaking+Bad&season=one&episode_number=1", problemInvalidRequest, "season"},
+		{"/api/movies/genre", problemInvalidRequest, "genre"},
+		{"/api/recommendations", problemInvalidRequest, "favorite_movie"},
+
+		{"/api/movie?title=Heat", problemNotFound, ""},
+		{"/api/movie/tt9999999", problemNotFound, ""},
+		{"/api/episode?series_title=Breaking+Bad&season=9&episode_number=1", problemNotFound, ""},
+		{"/api/movies/genre?genre=western", problemNotFound, ""},
+		{"/api/recommendations?favorite_movie=Heat", problemNotFound, ""},
+		{"/api/movies", problemNotFound, ""},
+	}
+	for _, tt := range tests {
+		recorder := get(router, tt.target)
+		if recorder.Code != tt.problem.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, recorder.Code, tt.problem.status)
+			continue
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != problemContentType {
+			t.Errorf("GET %s: Content-Type %q, want %q", tt.target, contentType, problemContentType)
+		}
+
+		var problem models.Problem
+		if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+			t.Errorf("GET %s: %v", tt.target, err)
+			continue
+		}
+		if problem.Type != tt.problem.uri() {
+			t.Errorf("GET %s: type %q, want %q", tt.target, problem.Type, tt.problem.uri())
+		}
+		if tt.param != "" && (len(problem.InvalidParams) != 1 || problem.InvalidParams[0].Name != tt.param) {
+			t.Errorf("GET %s: invalid params %+v, want %s", tt.target, problem.InvalidParams, tt.param)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-api/models"
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchPageSize = 10
+
+	// maxSearchPageSize bounds a page to five upstream OMDb pages
+	maxSearchPageSize = 50
+
+	// maxSearchResults is the number of results OMDb will page through
+	maxSearchResults = 1000
+)
+
+// Search handles GET /api/search?query=Matrix&type=movie&year=1999&page=1&page_size=10
+func (h *MovieHandler) Search(c *gin.Context) {
+	query := services.SearchQuery{
+		Query: c.Query("query"),
+		Type:  c.Query("type"),
+		Year:  c.Query("year"),
+	}
+	var invalid []models.InvalidParam
+	if query.Query == "" {
+		invalid = append(invalid, invalidParam("query", "is required"))
+	}
+	if query.Type != "" && !validTitleTypes[query.Type] {
+		invalid = append(invalid, invalidParam("type", "must be one of movie, series or episode"))
+	}
+	if query.Year != "" && !yearPattern.MatchString(query.Year) {
+		invalid = append(invalid, invalidParam("year", "must be a four digit year"))
+	}
+
+	var err error
+	if query.Page, err = strconv.Atoi(c.DefaultQuery("page", "1")); err != nil || query.Page < 1 {
+		invalid = append(invalid, invalidParam("page", "must be a positive integer"))
+	}
+	query.PageSize, err = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultSearchPageSize)))
+	if err != nil || query.PageSize < 1 || query.PageSize > maxSearchPageSize {
+		invalid = append(invalid, invalidParam("page_size", "must be an integer between 1 and "+strconv.Itoa(maxSearchPageSize)))
+	} else if lastPage := (maxSearchResults + query.PageSize - 1) / query.PageSize; query.Page > lastPage {
+		// OMDb serves no results past its first thousand
+		invalid = append(invalid, invalidParam("page", "must be at most "+strconv.Itoa(lastPage)+" for a page_size of "+strconv.Itoa(query.PageSize)))
+	}
+	if len(invalid) > 0 {
+		respondInvalid(c, invalid)
+		return
+	}
+
+	results, err := h.movies.Search(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, err, "No titles found for: "+query.Query)
+		return
+	}
+
+	// OMDb stops paging after its first thousand results
+	reachable := min(results.TotalResults, maxSearchResults)
+	totalPages := (reachable + query.PageSize - 1) / query.PageSize
+
+	response := models.SearchPageResponse{
+		Query:        query.Query,
+		Type:         query.Type,
+		Year:         query.Year,
+		Page:         query.Page,
+		PageSize:     query.PageSize,
+		TotalResults: results.TotalResults,
+		TotalPages:   totalPages,
+		Results:      make([]models.TitleSummary, len(results.Results)),
+	}
+	for i, result := range results.Results {
+		response.Results[i] = titleSummary(result)
+	}
+	if query.Page < totalPages {
+		response.Next = pageLink(c, query.Page+1)
+	}
+	if query.Page > 1 {
+		response.Prev = pageLink(c, min(query.Page-1, max(totalPages, 1)))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// pageLink returns the current request URL with its page parameter replaced
+func pageLink(c *gin.Context, page int) string {
+	params := c.Request.URL.Query()
+	params.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + params.Encode()
+}
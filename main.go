@@ -1,85 +1,323 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+	"time"
 
+	"go-api/config"
 	"go-api/handlers"
+	"go-api/middleware"
 	"go-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=..."
+var (
+	version = "dev"
+	commit  = ""
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using system environment variables")
+	envErr := godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("Invalid configuration", err)
 	}
 
-	// Get OMDb API key from environment
-	apiKey := os.Getenv("OMDB_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OMDB_API_KEY environment variable is required")
+	slog.SetDefault(newLogger(cfg.Log))
+	if envErr != nil {
+		slog.Warn(".env file not found, using system environment variables")
 	}
 
+	shutdownTracing, err := setupTracing(context.Background(), cfg.TracingExporter)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(ctx)
+	}()
+
 	// Initialize services
-	omdbService := services.NewOMDbService(apiKey)
+	keys := services.NewKeyPool(cfg.OMDbAPIKeys, services.KeyPoolConfig{
+		Selection:  services.KeySelection(cfg.OMDbKeySelection),
+		Quarantine: cfg.OMDbKeyQuarantine,
+	})
+	omdbOptions := []services.Option{
+		services.WithConcurrency(cfg.OMDbConcurrency),
+		services.WithReadinessProbe(cfg.ReadinessProbeInterval),
+		services.WithHTTPClient(&http.Client{Timeout: cfg.OMDbClientTimeout}),
+		services.WithRetryPolicy(services.RetryPolicy{
+			MaxAttempts: cfg.Retry.MaxAttempts,
+			BaseDelay:   cfg.Retry.BaseDelay,
+			MaxDelay:    cfg.Retry.MaxDelay,
+		}),
+	}
+	var cache *services.ResponseCache
+	if cfg.Cache.MaxEntries > 0 {
+		cache = services.NewResponseCache(services.CacheConfig{
+			MaxEntries:  cfg.Cache.MaxEntries,
+			DetailsTTL:  cfg.Cache.DetailsTTL,
+			SearchTTL:   cfg.Cache.SearchTTL,
+			EpisodeTTL:  cfg.Cache.EpisodeTTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+		})
+		omdbOptions = append(omdbOptions, services.WithCache(cache))
+	}
+	if cfg.Breaker.FailureThreshold > 0 {
+		omdbOptions = append(omdbOptions, services.WithCircuitBreaker(services.NewCircuitBreaker(services.BreakerConfig{
+			FailureThreshold: cfg.Breaker.FailureThreshold,
+			OpenDuration:     cfg.Breaker.OpenDuration,
+			HalfOpenProbes:   cfg.Breaker.HalfOpenProbes,
+		})))
+	}
+	if cfg.RateLimit.PerSecond > 0 {
+		omdbOptions = append(omdbOptions, services.WithRateLimiter(services.NewRateLimiter(cfg.RateLimit.PerSecond, cfg.RateLimit.Burst)))
+	}
+	var quota *services.QuotaTracker
+	if cfg.Quota.DailyLimit > 0 {
+		quota, err = services.OpenQuotaTracker(cfg.Quota.Path, services.QuotaConfig{
+			KeyIDs:     keys.IDs(),
+			DailyLimit: cfg.Quota.DailyLimit,
+			Reserve:    cfg.Quota.Reserve,
+			Location:   cfg.Quota.Location,
+		}, cfg.Store.FlushInterval)
+		if err != nil {
+			fatal("Failed to open quota counter", err)
+		}
+		defer func() {
+			if err := quota.Close(); err != nil {
+				slog.Error("Failed to save quota counter", "error", err)
+			}
+		}()
+
+		omdbOptions = append(omdbOptions, services.WithQuota(quota))
+	}
+	if cfg.OMDbBaseURL != "" {
+		omdbOptions = append(omdbOptions, services.WithBaseURL(cfg.OMDbBaseURL))
+	}
+	if cfg.Store.Path != "" {
+		store, err := services.OpenFileStore(cfg.Store.Path, cfg.Store.FlushInterval)
+		if err != nil {
+			fatal("Failed to open metadata store", err)
+		}
+		defer func() {
+			if err := store.Close(); err != nil {
+				slog.Error("Failed to save metadata store", "error", err)
+			}
+		}()
+
+		slog.Info("Loaded stored OMDb records", "records", store.Len(), "path", cfg.Store.Path)
+		omdbOptions = append(omdbOptions, services.WithStore(store, cfg.Store.StaleAfter))
+	}
+	omdbService := services.NewOMDbServiceWithKeys(keys, omdbOptions...)
+	prometheus.MustRegister(services.StatsCollector{Cache: cache, Quota: quota})
 
 	// Initialize handlers
 	movieHandler := handlers.NewMovieHandler(omdbService)
+	build := buildInfo()
+	healthHandler := handlers.NewHealthHandler(omdbService, build)
+	adminHandler := handlers.NewAdminHandler(keys, quota)
+
+	// Configuration guarantees a key file unless authentication was
+	// explicitly turned off
+	var clientKeys *services.ClientKeyStore
+	if cfg.AuthDisabled {
+		slog.Warn("AUTH_DISABLED is set, /api and /admin are open to anyone")
+	} else {
+		clientKeys, err = services.OpenClientKeyStore(cfg.ClientKeysPath)
+		if err != nil {
+			fatal("Failed to open client keys", err)
+		}
+		slog.Info("Loaded client API keys", "keys", len(clientKeys.List()), "path", cfg.ClientKeysPath)
+	}
+
+	// Setup Gin router. Release mode keeps gin's plain-text debug lines
+	// off stdout; routes are logged below instead.
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
 
-	// Setup Gin router
-	router := gin.Default()
+	// Tag every request with an ID; error responses report it as their
+	// instance and every log line written while handling it carries it.
+	// Then trace, log, time and recover from panics in every request.
+	router.Use(middleware.RequestID(), middleware.Tracing(), middleware.AccessLog(), middleware.Metrics(), middleware.Recovery())
 
-	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	// Cross-origin policy for browser clients
+	router.Use(middleware.CORS(middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}, router))
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	// Health check endpoints
+	router.GET("/health", healthHandler.HealthCheck)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
 
-		c.Next()
-	})
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	// Health check endpoint
-	router.GET("/health", movieHandler.HealthCheck)
+	// Per-client limits; endpoints cost more the more upstream calls they make
+	limiter := middleware.NewRateLimiter(
+		middleware.Rate{PerMinute: cfg.Inbound.IPPerMinute, Burst: cfg.Inbound.IPBurst},
+		middleware.Rate{PerMinute: cfg.Inbound.KeyPerMinute, Burst: cfg.Inbound.KeyBurst},
+	)
+	costs := middleware.RouteCosts{
+		"/api/movie":           cfg.Inbound.Costs.Movie,
+		"/api/movie/:imdbID":   cfg.Inbound.Costs.Movie,
+		"/api/search":          cfg.Inbound.Costs.Search,
+		"/api/episode":         cfg.Inbound.Costs.Episode,
+		"/api/movies/genre":    cfg.Inbound.Costs.Genre,
+		"/api/recommendations": cfg.Inbound.Costs.Recommendations,
+	}
 
-	// API routes
+	// API routes. The IP bucket is charged before authentication so that
+	// failed attempts count against it; the key bucket once the client is
+	// known.
 	api := router.Group("/api")
+	api.Use(limiter.LimitIP(costs))
+	if clientKeys != nil {
+		api.Use(middleware.RequireScope(clientKeys, services.ScopeAPI))
+	}
+	api.Use(limiter.LimitKey(costs))
 	{
-		// Movie Details API - /api/movie?title=The Matrix
-		api.GET("/movie", movieHandler.GetMovieDetails)
+		// Movie Details API - /api/movie?title=The Matrix&year=1999&type=movie
+		api.GET("/movie", middleware.Deadline(cfg.Timeouts.Movie), movieHandler.GetMovieDetails)
+
+		// Movie Details by IMDb ID API - /api/movie/tt0133093
+		api.GET("/movie/:imdbID", middleware.Deadline(cfg.Timeouts.Movie), movieHandler.GetMovieByID)
+
+		// Search API - /api/search?query=Matrix&type=movie&year=1999&page=1&page_size=20
+		api.GET("/search", middleware.Deadline(cfg.Timeouts.Search), movieHandler.Search)
 
 		// Episode Details API - /api/episode?series_title=Breaking Bad&season=1&episode_number=1
-		api.GET("/episode", movieHandler.GetEpisodeDetails)
+		api.GET("/episode", middleware.Deadline(cfg.Timeouts.Episode), movieHandler.GetEpisodeDetails)
 
 		// Genre-Based Movies API - /api/movies/genre?genre=Action
-		api.GET("/movies/genre", movieHandler.GetMoviesByGenre)
+		api.GET("/movies/genre", middleware.Deadline(cfg.Timeouts.Genre), movieHandler.GetMoviesByGenre)
 
 		// Movie Recommendations API - /api/recommendations?favorite_movie=The Matrix
-		api.GET("/recommendations", movieHandler.GetRecommendations)
+		api.GET("/recommendations", middleware.Deadline(cfg.Timeouts.Recommendations), movieHandler.GetRecommendations)
+	}
+
+	// Admin routes
+	admin := router.Group("/admin")
+	if clientKeys != nil {
+		admin.Use(middleware.RequireScope(clientKeys, services.ScopeAdmin))
+	}
+	{
+		// OMDb Quota API - /admin/quota
+		admin.GET("/quota", adminHandler.GetQuota)
+
+		// OMDb API Key Stats API - /admin/keys
+		admin.GET("/keys", adminHandler.GetKeys)
+	}
+
+	router.NoRoute(handlers.RouteNotFound)
+
+	port := cfg.Port
+
+	slog.Info("Starting server", "port", port, "version", build.Version, "commit", build.Commit)
+	slog.Info("Endpoint available", "route", "GET /health", "description", "Health check")
+	slog.Info("Endpoint available", "route", "GET /livez", "description", "Liveness probe")
+	slog.Info("Endpoint available", "route", "GET /readyz", "description", "Readiness probe with per-check detail")
+	slog.Info("Endpoint available", "route", "GET /metrics", "description", "Prometheus metrics")
+	slog.Info("Endpoint available", "route", "GET /api/movie?title=<movie_title>[&year=<year>][&type=<type>]", "description", "Get movie details")
+	slog.Info("Endpoint available", "route", "GET /api/movie/<imdb_id>", "description", "Get movie details by IMDb ID")
+	slog.Info("Endpoint available", "route", "GET /api/search?query=<text>[&type=<type>][&year=<year>][&page=<n>][&page_size=<n>]", "description", "Search titles")
+	slog.Info("Endpoint available", "route", "GET /api/episode?series_title=<series>&season=<num>&episode_number=<num>", "description", "Get episode details")
+	slog.Info("Endpoint available", "route", "GET /api/movies/genre?genre=<genre>", "description", "Get top 15 movies by genre")
+	slog.Info("Endpoint available", "route", "GET /api/recommendations?favorite_movie=<movie_title>", "description", "Get movie recommendations")
+	slog.Info("Endpoint available", "route", "GET /admin/quota", "description", "Remaining OMDb daily quota")
+	slog.Info("Endpoint available", "route", "GET /admin/keys", "description", "OMDb API key usage")
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		fatal("Failed to start server", err)
+	case <-ctx.Done():
 	}
+	// A second signal stops the process without waiting
+	stop()
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Stop accepting connections and let in-flight requests finish, then
+	// wait for background refreshes before the store and quota counter are
+	// saved by the deferred closes
+	slog.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("In-flight requests did not finish in time, closing their connections", "error", err)
+		server.Close()
+	}
+	if err := omdbService.Close(shutdownCtx); err != nil {
+		slog.Warn("Stopped waiting for background refreshes", "error", err)
 	}
+	slog.Info("Server stopped")
+}
 
-	log.Printf("Starting server on port %s", port)
-	log.Printf("Available endpoints:")
-	log.Printf("  GET /health - Health check")
-	log.Printf("  GET /api/movie?title=<movie_title> - Get movie details")
-	log.Printf("  GET /api/episode?series_title=<series>&season=<num>&episode_number=<num> - Get episode details")
-	log.Printf("  GET /api/movies/genre?genre=<genre> - Get top 15 movies by genre")
-	log.Printf("  GET /api/recommendations?favorite_movie=<movie_title> - Get movie recommendations")
+// buildInfo returns the version and commit set at build time. Without an
+// injected commit, the one recorded by the Go toolchain is used, if any.
+func buildInfo() handlers.BuildInfo {
+	build := handlers.BuildInfo{Version: version, Commit: commit}
+	if build.Commit == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" {
+					build.Commit = setting.Value
+				}
+			}
+		}
+	}
+	return build
+}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+// newLogger builds the process logger from the configuration
+func newLogger(logConfig config.Log) *slog.Logger {
+	options := &slog.HandlerOptions{Level: logConfig.Level}
+	if logConfig.Format == "text" {
+		return slog.New(slog.NewTextHandler(os.Stderr, options))
 	}
+	return slog.New(slog.NewJSONHandler(os.Stderr, options))
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientContextKey is the gin context key holding the authenticated client
+const clientContextKey = "client"
+
+// RequireScope returns middleware that admits only requests carrying a
+// client API key with the given scope, in the X-API-Key header or as a
+// bearer token. The client is stored on the context for later handlers,
+// logging and rate limiting; see GetClient.
+func RequireScope(keys *services.ClientKeyStore, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := presentedKey(c)
+		if presented == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			abortWithProblem(c, http.StatusUnauthorized, "unauthenticated", "Authentication required",
+				"Send an API key in the "+APIKeyHeader+" header or as a bearer token")
+			return
+		}
+
+		client, ok := keys.Authenticate(presented)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			abortWithProblem(c, http.StatusUnauthorized, "unauthenticated", "Authentication required",
+				"The API key is unknown or has been revoked")
+			return
+		}
+		c.Set(clientContextKey, client)
+		ctx := c.Request.Context()
+		c.Request = c.Request.WithContext(services.WithLogger(ctx, services.Logger(ctx).With("client", client.ID)))
+
+		if !client.HasScope(scope) {
+			abortWithProblem(c, http.StatusForbidden, "forbidden", "Forbidden",
+				"This API key does not have the "+scope+" scope")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetClient returns the client that authenticated the request, or nil
+func GetClient(c *gin.Context) *services.Client {
+	if v, ok := c.Get(clientContextKey); ok {
+		if client, ok := v.(*services.Client); ok {
+			return client
+		}
+	}
+	return nil
+}
+
+// presentedKey returns the key from the X-API-Key header, falling back to
+// an Authorization bearer token
+func presentedKey(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-api/models"
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authFixture is a router guarding /api and /admin with client keys, and
+// keys for each kind of client
+type authFixture struct {
+	router  *gin.Engine
+	store   *services.ClientKeyStore
+	api     string // api scope
+	admin   string // admin scope only
+	revoked string
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	store, err := services.OpenClientKeyStore(filepath.Join(t.TempDir(), "client_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &authFixture{store: store}
+	create := func(name string, scopes ...string) (string, services.ClientKeyInfo) {
+		key, info, err := store.Create(name, scopes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, info
+	}
+	f.api, _ = create("frontend", services.ScopeAPI)
+	f.admin, _ = create("ops", services.ScopeAdmin)
+	var revoked services.ClientKeyInfo
+	f.revoked, revoked = create("former partner", services.ScopeAPI)
+	if err := store.Revoke(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.router = gin.New()
+	whoami := func(c *gin.Context) { c.String(http.StatusOK, GetClient(c).Name) }
+	f.router.GET("/api/movie", RequireScope(store, services.ScopeAPI), whoami)
+	f.router.GET("/admin/keys", RequireScope(store, services.ScopeAdmin), whoami)
+	return f
+}
+
+func (f *authFixture) get(target string, headers map[string]string) *httptest.ResponseRecorder {
+	return serve(f.router, http.MethodGet, target, headers)
+}
+
+// checkProblem fails the test unless recorder holds a problem document of
+// the given status and type
+func checkProblem(t *testing.T, recorder *httptest.ResponseRecorder, status int, slug string) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("status %d, want %d", recorder.Code, status)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "/problems/"+slug {
+		t.Errorf("problem type %q, want /problems/%s", problem.Type, slug)
+	}
+}
+
+func TestAuthAcceptsHeaderAndBearer(t *testing.T) {
+	f := newAuthFixture(t)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"api key header", map[string]string{APIKeyHeader: f.api}},
+		{"bearer token", map[string]string{"Authorization": "Bearer " + f.api}},
+		{"lower case scheme", map[string]string{"Authorization": "bearer " + f.api}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := f.get("/api/movie", tt.headers)
+			if recorder.Code != http.StatusOK || recorder.Body.String() != "frontend" {
+				t.Errorf("status %d, body %q, want 200 for frontend", recorder.Code, recorder.Body)
+			}
+		})
+	}
+
+	// The header wins over a bearer token
+	recorder := f.get("/api/movie", map[string]string{APIKeyHeader: f.revoked, "Authorization": "Bearer " + f.api})
+	checkProblem(t, recorder, http.StatusUnauthorized, "unauthenticated")
+}
+
+func TestAuthRequiresKey(t *testing.T) {
+	f := newAuthFixture(t)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"none", nil},
+		{"other scheme", map[string]string{"Authorization": "Basic " + f.api}},
+		{"empty bearer", map[string]string{"Authorization": "Bearer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := f.get("/api/movie", tt.headers)
+			checkProblem(t, recorder, http.StatusUnauthorized, "unauthenticated")
+			if got := recorder.Header().Get("WWW-Authenticate"); got != `Bearer realm="api"` {
+				t.Errorf("WWW-Authenticate = %q", got)
+			}
+		})
+	}
+}
+
+func TestAuthRejectsUnknownKeys(t *testing.T) {
+	f := newAuthFixture(t)
+	id, _, _ := strings.Cut(f.api, ".")
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"revoked", f.revoked},
+		{"wrong secret", id + ".not-the-secret"},
+		{"unknown id", "00000000.secret"},
+		{"no id", "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := f.get("/api/movie", map[string]string{APIKeyHeader: tt.key})
+			checkProblem(t, recorder, http.StatusUnauthorized, "unauthenticated")
+			if got := recorder.Header().Get("WWW-Authenticate"); !strings.Contains(got, `error="invalid_token"`) {
+				t.Errorf("WWW-Authenticate = %q, want an invalid_token error", got)
+			}
+		})
+	}
+}
+
+func TestAuthChecksScope(t *testing.T) {
+	f := newAuthFixture(t)
+
+	checkProblem(t, f.get("/admin/keys", map[string]string{APIKeyHeader: f.api}), http.StatusForbidden, "forbidden")
+	checkProblem(t, f.get("/api/movie", map[string]string{APIKeyHeader: f.admin}), http.StatusForbidden, "forbidden")
+
+	if recorder := f.get("/admin/keys", map[string]string{APIKeyHeader: f.admin}); recorder.Code != http.StatusOK {
+		t.Errorf("admin key on /admin: status %d, want 200", recorder.Code)
+	}
+}
+
+func TestAuthRevokeTakesEffect(t *testing.T) {
+	f := newAuthFixture(t)
+	headers := map[string]string{APIKeyHeader: f.api}
+	if recorder := f.get("/api/movie", headers); recorder.Code != http.StatusOK {
+		t.Fatalf("before revoking: status %d, want 200", recorder.Code)
+	}
+
+	id, _, _ := strings.Cut(f.api, ".")
+	if err := f.store.Revoke(id); err != nil {
+		t.Fatal(err)
+	}
+	checkProblem(t, f.get("/api/movie", headers), http.StatusUnauthorized, "unauthenticated")
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig is a cross-origin policy. AllowedOrigins are exact origins or
+// path.Match patterns such as https://*.example.com; a lone * allows any
+// origin.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// RouteLister is satisfied by *gin.Engine
+type RouteLister interface {
+	Routes() gin.RoutesInfo
+}
+
+// CORS returns middleware applying config to cross-origin requests. The
+// methods offered to a preflight request are those registered on routes
+// for its path, read from routes on first use so that the middleware can
+// be installed before the routes are.
+func CORS(config CORSConfig, routes RouteLister) gin.HandlerFunc {
+	anyOrigin := slices.Contains(config.AllowedOrigins, "*")
+	origins := make([]string, len(config.AllowedOrigins))
+	for i, origin := range config.AllowedOrigins {
+		origins[i] = strings.ToLower(origin)
+	}
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	var (
+		once       sync.Once
+		registered gin.RoutesInfo
+	)
+	methodsFor := func(requestPath string) []string {
+		once.Do(func() { registered = routes.Routes() })
+		var methods []string
+		for _, route := range registered {
+			if !slices.Contains(methods, route.Method) && routeMatches(route.Path, requestPath) {
+				methods = append(methods, route.Method)
+			}
+		}
+		return methods
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// The answer depends on these request headers, so shared caches
+		// must key on them, including when no CORS headers are sent
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !anyOrigin && !originAllowed(origins, strings.ToLower(origin)) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin && !config.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposedHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposedHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		methods := methodsFor(c.Request.URL.Path)
+		if len(methods) == 0 {
+			// Let the request fall through to the not found handler
+			c.Next()
+			return
+		}
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if allowedHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowedHeaders)
+		}
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func originAllowed(patterns []string, origin string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMatches reports whether a request path is served by a gin route
+// pattern, where :name matches one segment and *name the rest of the path
+func routeMatches(pattern, requestPath string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(requestPath, "/"), "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCORSRouter returns a router with the CORS policy and a few routes
+// shaped like the API's
+func newCORSRouter(config CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(config, router))
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/movie", ok)
+	router.GET("/api/movie/:imdbID", ok)
+	router.PUT("/api/movie/:imdbID", ok)
+	return router
+}
+
+func serve(router *gin.Engine, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+var patternPolicy = CORSConfig{
+	AllowedOrigins: []string{"https://app.example.org", "https://*.example.com"},
+	AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+	ExposedHeaders: []string{"X-Request-ID"},
+	MaxAge:         10 * time.Minute,
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	router := newCORSRouter(patternPolicy)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.org", true},
+		{"https://APP.example.org", true},
+		{"https://a.example.com", true},
+		{"https://a.example.com.evil.com", false},
+		{"https://example.com", false},
+		{"http://a.example.com", false},
+		{"https://app.example.org.evil.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			resp := serve(router, http.MethodGet, "/api/movie", map[string]string{"Origin": tt.origin})
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+			}
+
+			got := resp.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed && got != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if !tt.allowed && got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q for a disallowed origin", got)
+			}
+			if tt.allowed && resp.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+				t.Errorf("Access-Control-Expose-Headers = %q", resp.Header().Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+}
+
+func TestCORSPreflightFromDisallowedOrigin(t *testing.T) {
+	router := newCORSRouter(patternPolicy)
+
+	resp := serve(router, http.MethodOptions, "/api/movie", map[string]string{
+		"Origin":                        "https://a.example.com.evil.com",
+		"Access-Control-Request-Method": http.MethodGet,
+	})
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", resp.Code, http.StatusForbidden)
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for a disallowed origin", got)
+	}
+}
+
+func TestCORSPreflightMethodsFromRoutes(t *testing.T) {
+	router := newCORSRouter(patternPolicy)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/movie", "GET"},
+		{"/api/movie/tt0133093", "GET, PUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := serve(router, http.MethodOptions, tt.path, map[string]string{
+				"Origin":                         "https://a.example.com",
+				"Access-Control-Request-Method":  http.MethodGet,
+				"Access-Control-Request-Headers": "X-API-Key",
+			})
+			if resp.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want %d", resp.Code, http.StatusNoContent)
+			}
+
+			header := resp.Header()
+			if got := header.Get("Access-Control-Allow-Methods"); got != tt.want {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.want)
+			}
+			if got := header.Get("Access-Control-Allow-Headers"); got != "Content-Type, X-API-Key" {
+				t.Errorf("Access-Control-Allow-Headers = %q", got)
+			}
+			if got := header.Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+			}
+			for _, vary := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+				if !slices.Contains(header.Values("Vary"), vary) {
+					t.Errorf("Vary %v does not include %s", header.Values("Vary"), vary)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSVaryWithoutOrigin(t *testing.T) {
+	router := newCORSRouter(patternPolicy)
+
+	resp := serve(router, http.MethodGet, "/api/movie", nil)
+	if !slices.Contains(resp.Header().Values("Vary"), "Origin") {
+		t.Errorf("Vary = %v, want it to include Origin", resp.Header().Values("Vary"))
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q on a same-origin request", got)
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+	}{
+		{"explicit origin", []string{"https://app.example.org"}},
+		{"any origin", []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newCORSRouter(CORSConfig{AllowedOrigins: tt.origins, AllowCredentials: true})
+
+			resp := serve(router, http.MethodGet, "/api/movie", map[string]string{"Origin": "https://app.example.org"})
+			if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.org" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+			}
+			if got := resp.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+			}
+		})
+	}
+}
+
+func TestCORSAnyOriginWithoutCredentials(t *testing.T) {
+	router := newCORSRouter(CORSConfig{AllowedOrigins: []string{"*"}})
+
+	resp := serve(router, http.MethodGet, "/api/movie", map[string]string{"Origin": "https://anywhere.test"})
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := resp.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q without credentials mode", got)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog logs every request once it has been handled, through the
+// request's logger so that the line carries the request ID and, once
+// authenticated, the client. Server errors
+// are logged at error level and client errors at warn level.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"query", c.Request.URL.RawQuery,
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+
+		ctx := c.Request.Context()
+		services.Logger(ctx).Log(ctx, level, "request", attrs...)
+	}
+}
+
+// Recovery answers a request whose handler panicked with a 500 problem
+// document and logs the panic with its stack
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		ctx := c.Request.Context()
+		services.Logger(ctx).ErrorContext(ctx, "panic while handling request",
+			"error", recovered,
+			"stack", string(debug.Stack()),
+		)
+		abortWithProblem(c, http.StatusInternalServerError, "internal", "Internal server error",
+			"An unexpected error occurred")
+	})
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"go-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// abortWithProblem rejects a request with an RFC 7807 problem document of
+// the same shape the handlers send
+func abortWithProblem(c *gin.Context, status int, slug, title, detail string) {
+	body := models.Problem{
+		Type:   "/problems/" + slug,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+	if id := GetRequestID(c); id != "" {
+		body.Instance = "urn:request:" + id
+	}
+
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, body)
+}
@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the client API key
+const APIKeyHeader = "X-API-Key"
+
+// bucketIdleSweep is how often buckets that have refilled are dropped
+const bucketIdleSweep = time.Minute
+
+// Rate sizes a family of token buckets: each holds up to Burst tokens and
+// refills at PerMinute tokens a minute. A PerMinute of zero disables it.
+type Rate struct {
+	PerMinute float64
+	Burst     int
+}
+
+// RateLimiter limits inbound requests with token buckets, one per client IP
+// and one per client API key. A request must fit in both of the buckets
+// that apply to it, so rotating keys does not get around the IP limit and
+// spreading a key over many addresses does not get around the key limit.
+type RateLimiter struct {
+	ip  Rate
+	key Rate
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(ip, key Rate) *RateLimiter {
+	return &RateLimiter{
+		ip:        ip,
+		key:       key,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// RouteCosts are the tokens a request takes from the buckets, keyed by the
+// full path of the route it matched, so that endpoints fanning out to many
+// upstream calls can be made to cost more. Routes not listed cost 1.
+type RouteCosts map[string]int
+
+func (costs RouteCosts) of(c *gin.Context) int {
+	if cost, ok := costs[c.FullPath()]; ok {
+		return cost
+	}
+	return 1
+}
+
+// LimitIP returns middleware that charges every request to the bucket of
+// its client IP. It belongs before authentication so that requests failing
+// it are charged too.
+func (l *RateLimiter) LimitIP(costs RouteCosts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.ip.PerMinute <= 0 {
+			c.Next()
+			return
+		}
+		l.limit(c, bucketCheck{id: "ip:" + c.ClientIP(), rate: l.ip}, costs.of(c))
+	}
+}
+
+// LimitKey returns middleware that charges every request to the bucket of
+// its client API key. It belongs after authentication, which identifies
+// the client.
+func (l *RateLimiter) LimitKey(costs RouteCosts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := clientID(c)
+		if id == "" || l.key.PerMinute <= 0 {
+			c.Next()
+			return
+		}
+		l.limit(c, bucketCheck{id: "key:" + id, rate: l.key}, costs.of(c))
+	}
+}
+
+// limit charges cost to the bucket of check. Every response carries
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers for the
+// tightest bucket the request was charged to; a request that does not fit
+// is answered with 429 and Retry-After.
+func (l *RateLimiter) limit(c *gin.Context, check bucketCheck, cost int) {
+	allowed := l.take(&check, cost, time.Now())
+
+	if tightest, ok := c.Get(tightestBucketKey); !ok || !allowed || check.tighterThan(tightest.(bucketCheck)) {
+		c.Set(tightestBucketKey, check)
+		c.Header("RateLimit-Limit", strconv.Itoa(check.rate.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(int(check.remaining)))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(check.reset)))
+	}
+
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(max(seconds(check.retryAfter), 1)))
+		abortWithProblem(c, http.StatusTooManyRequests, "rate-limited", "Too many requests",
+			"This client has made too many requests; please slow down")
+		return
+	}
+
+	c.Next()
+}
+
+// tightestBucketKey is the gin context key holding the bucket reported in
+// the RateLimit headers
+const tightestBucketKey = "ratelimit_bucket"
+
+type bucketCheck struct {
+	id   string
+	rate Rate
+
+	// Filled in by take
+	remaining  float64
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the request would fit
+}
+
+// tighterThan reports whether check has fewer tokens left relative to its
+// size than other
+func (check bucketCheck) tighterThan(other bucketCheck) bool {
+	return check.remaining/float64(check.rate.Burst) < other.remaining/float64(other.rate.Burst)
+}
+
+// take charges cost to the bucket of check if it fits and fills in the
+// bucket's state
+func (l *RateLimiter) take(check *bucketCheck, cost int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b := l.bucket(check.id, check.rate, now)
+	check.rate = b.rate
+	// A cost larger than the bucket could never be paid
+	need := math.Min(float64(cost), float64(b.rate.Burst))
+	allowed := b.tokens >= need
+	if allowed {
+		b.tokens -= need
+	}
+
+	perSecond := b.rate.PerMinute / 60
+	check.remaining = math.Max(b.tokens, 0)
+	check.reset = time.Duration((float64(b.rate.Burst) - b.tokens) / perSecond * float64(time.Second))
+	check.retryAfter = time.Duration(math.Max(need-b.tokens, 0) / perSecond * float64(time.Second))
+	return allowed
+}
+
+// bucket returns the refilled bucket for id, creating a full one if needed.
+// l.mu must be held.
+func (l *RateLimiter) bucket(id string, rate Rate, now time.Time) *bucket {
+	rate.Burst = max(rate.Burst, 1)
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{rate: rate, tokens: float64(rate.Burst), last: now}
+		l.buckets[id] = b
+		return b
+	}
+	b.tokens = math.Min(float64(rate.Burst), b.tokens+now.Sub(b.last).Minutes()*rate.PerMinute)
+	b.last = now
+	return b
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would be identical. l.mu must be held.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleSweep {
+		return
+	}
+	l.lastSweep = now
+	for id, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Minutes()*b.rate.PerMinute >= float64(b.rate.Burst) {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// clientID identifies the client for the per-key bucket: the authenticated
+// client when there is one, otherwise a fingerprint of the presented key
+// so that limits still apply when authentication is turned off
+func clientID(c *gin.Context) string {
+	if client := GetClient(c); client != nil {
+		return client.ID
+	}
+	if key := presentedKey(c); key != "" {
+		return fingerprint(key)
+	}
+	return ""
+}
+
+// fingerprint identifies an API key without keeping its value around
+func fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"go-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitRouter returns a router limited like the API's, where the
+// genre route costs 5 and the recommendations route 20
+func newRateLimitRouter(l *RateLimiter) *gin.Engine {
+	costs := RouteCosts{"/api/movies/genre": 5, "/api/recommendations": 20}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(l.LimitIP(costs))
+	api := router.Group("/api", l.LimitKey(costs))
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/movie", ok)
+	api.GET("/movies/genre", ok)
+	api.GET("/recommendations", ok)
+	return router
+}
+
+// request sends a GET from the client at ip, with an API key if key is set
+func request(router *gin.Engine, target, ip, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = ip + ":40000"
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// checkLimited fails the test unless recorder holds the given status and
+// RateLimit headers
+func checkLimited(t *testing.T, recorder *httptest.ResponseRecorder, status int, limit, remaining string) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Errorf("status %d, want %d", recorder.Code, status)
+	}
+	if got := recorder.Header().Get("RateLimit-Limit"); got != limit {
+		t.Errorf("RateLimit-Limit = %q, want %q", got, limit)
+	}
+	if got := recorder.Header().Get("RateLimit-Remaining"); got != remaining {
+		t.Errorf("RateLimit-Remaining = %q, want %q", got, remaining)
+	}
+}
+
+func TestRateLimitCostWeighting(t *testing.T) {
+	// 0.1 tokens a second, so nothing refills during the test
+	router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 10}, Rate{}))
+
+	checkLimited(t, request(router, "/api/movie", "192.0.2.1", ""), http.StatusOK, "10", "9")
+	checkLimited(t, request(router, "/api/movies/genre", "192.0.2.1", ""), http.StatusOK, "10", "4")
+
+	// The genre route needs 5 tokens; the 4 left are not charged
+	recorder := request(router, "/api/movies/genre", "192.0.2.1", "")
+	checkLimited(t, recorder, http.StatusTooManyRequests, "10", "4")
+	if got := recorder.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want the 10s one token takes", got)
+	}
+
+	for range 4 {
+		if recorder := request(router, "/api/movie", "192.0.2.1", ""); recorder.Code != http.StatusOK {
+			t.Fatalf("cheap request after a refused expensive one: status %d", recorder.Code)
+		}
+	}
+	checkLimited(t, request(router, "/api/movie", "192.0.2.1", ""), http.StatusTooManyRequests, "10", "0")
+}
+
+func TestRateLimitCostAboveBurst(t *testing.T) {
+	// The recommendations route costs 20, more than the bucket holds
+	router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 10}, Rate{}))
+
+	checkLimited(t, request(router, "/api/recommendations", "192.0.2.1", ""), http.StatusOK, "10", "0")
+	checkLimited(t, request(router, "/api/recommendations", "192.0.2.1", ""), http.StatusTooManyRequests, "10", "0")
+}
+
+func TestRateLimitHeadersReportTightestBucket(t *testing.T) {
+	t.Run("key", func(t *testing.T) {
+		router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 100}, Rate{PerMinute: 6, Burst: 5}))
+		checkLimited(t, request(router, "/api/movie", "192.0.2.1", "k1"), http.StatusOK, "5", "4")
+
+		// Without a key only the IP bucket applies
+		checkLimited(t, request(router, "/api/movie", "192.0.2.1", ""), http.StatusOK, "100", "98")
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 3}, Rate{PerMinute: 6, Burst: 100}))
+		checkLimited(t, request(router, "/api/movie", "192.0.2.1", "k1"), http.StatusOK, "3", "2")
+	})
+
+	t.Run("refusing bucket", func(t *testing.T) {
+		router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 10}, Rate{PerMinute: 6, Burst: 100}))
+
+		// Spend 85 of the key's tokens from fresh addresses
+		for i := range 4 {
+			request(router, "/api/recommendations", "192.0.2."+strconv.Itoa(i+1), "k1")
+		}
+		for range 5 {
+			request(router, "/api/movie", "192.0.2.5", "k1")
+		}
+
+		// The new address's bucket is emptied, a smaller share than the key
+		// has left, but the key's bucket is the one refusing
+		recorder := request(router, "/api/recommendations", "192.0.2.6", "k1")
+		checkLimited(t, recorder, http.StatusTooManyRequests, "100", "15")
+		if got := recorder.Header().Get("Retry-After"); got != "50" {
+			t.Errorf("Retry-After = %q, want the 50s 5 tokens take", got)
+		}
+	})
+}
+
+func TestRateLimitKeysAcrossIPs(t *testing.T) {
+	// A key spread over addresses shares one bucket
+	router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 100}, Rate{PerMinute: 6, Burst: 2}))
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if recorder := request(router, "/api/movie", ip, "k1"); recorder.Code != http.StatusOK {
+			t.Fatalf("from %s: status %d", ip, recorder.Code)
+		}
+	}
+	if recorder := request(router, "/api/movie", "192.0.2.3", "k1"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("third request with the key: status %d, want 429", recorder.Code)
+	}
+
+	// Rotating keys from one address does not get around the IP bucket
+	router = newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 6, Burst: 2}, Rate{PerMinute: 6, Burst: 100}))
+	for _, key := range []string{"k1", "k2"} {
+		if recorder := request(router, "/api/movie", "192.0.2.1", key); recorder.Code != http.StatusOK {
+			t.Fatalf("with %s: status %d", key, recorder.Code)
+		}
+	}
+	if recorder := request(router, "/api/movie", "192.0.2.1", "k3"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("third key from one address: status %d, want 429", recorder.Code)
+	}
+}
+
+func TestRateLimitRefusal(t *testing.T) {
+	router := newRateLimitRouter(NewRateLimiter(Rate{PerMinute: 60, Burst: 1}, Rate{}))
+	request(router, "/api/movie", "192.0.2.1", "")
+
+	recorder := request(router, "/api/movie", "192.0.2.1", "")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429", recorder.Code)
+	}
+	if got := recorder.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", got)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "/problems/rate-limited" || problem.Status != http.StatusTooManyRequests {
+		t.Errorf("problem = %+v, want rate-limited", problem)
+	}
+
+	// Other addresses are unaffected
+	if recorder := request(router, "/api/movie", "192.0.2.2", ""); recorder.Code != http.StatusOK {
+		t.Errorf("another address: status %d, want 200", recorder.Code)
+	}
+}
@@ -40,6 +40,7 @@ type Rating struct {
 
 // MovieDetailsResponse represents the cleaned response for movie details
 type MovieDetailsResponse struct {
+	ImdbID   string   `json:"imdb_id"`
 	Title    string   `json:"title"`
 	Year     string   `json:"year"`
 	Plot     string   `json:"plot"`
@@ -49,6 +50,23 @@ type MovieDetailsResponse struct {
 	Ratings  []Rating `json:"ratings"`
 }
 
+// TitleSummary represents a search hit or one of several titles matching an ambiguous lookup
+type TitleSummary struct {
+	Title  string `json:"title"`
+	Year   string `json:"year"`
+	ImdbID string `json:"imdb_id"`
+	Type   string `json:"type"`
+	Poster string `json:"poster"`
+}
+
+// MultipleChoicesResponse lists the candidates for an ambiguous title lookup
+type MultipleChoicesResponse struct {
+	Error      string         `json:"error"`
+	Message    string         `json:"message"`
+	Code       int            `json:"code"`
+	Candidates []TitleSummary `json:"candidates"`
+}
+
 // EpisodeDetailsResponse represents the cleaned response for episode details
 type EpisodeDetailsResponse struct {
 	Title       string   `json:"title"`
@@ -65,13 +83,19 @@ type EpisodeDetailsResponse struct {
 
 // GenreMoviesResponse represents the response for genre-based movies
 type GenreMoviesResponse struct {
-	Genre  string       `json:"genre"`
-	Movies []MovieBrief `json:"movies"`
-	Count  int          `json:"count"`
+	Genre   string       `json:"genre"`
+	Movies  []MovieBrief `json:"movies"`
+	Count   int          `json:"count"`
+	Partial bool         `json:"partial,omitempty"`
+
+	// FailedRequests counts the upstream lookups that failed even after
+	// retrying; when non-zero the list may be missing movies
+	FailedRequests int `json:"failed_requests"`
 }
 
 // MovieBrief represents a brief movie information
 type MovieBrief struct {
+	ImdbID     string `json:"imdb_id"`
 	Title      string `json:"title"`
 	Year       string `json:"year"`
 	ImdbRating string `json:"imdb_rating"`
@@ -84,6 +108,11 @@ type MovieBrief struct {
 type RecommendationsResponse struct {
 	FavoriteMovie   string                    `json:"favorite_movie"`
 	Recommendations RecommendationsByCategory `json:"recommendations"`
+	Partial         bool                      `json:"partial,omitempty"`
+
+	// FailedRequests counts the upstream lookups that failed even after
+	// retrying; when non-zero some categories may be missing movies
+	FailedRequests int `json:"failed_requests"`
 }
 
 // RecommendationsByCategory categorizes recommendations by priority
@@ -110,9 +139,37 @@ type SearchResult struct {
 	Poster string `json:"Poster"`
 }
 
-// ErrorResponse represents API error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-	Code    int    `json:"code"`
+// SearchPageResponse represents one page of search results
+type SearchPageResponse struct {
+	Query        string         `json:"query"`
+	Type         string         `json:"type,omitempty"`
+	Year         string         `json:"year,omitempty"`
+	Page         int            `json:"page"`
+	PageSize     int            `json:"page_size"`
+	TotalResults int            `json:"total_results"`
+	TotalPages   int            `json:"total_pages"`
+	Results      []TitleSummary `json:"results"`
+	Next         string         `json:"next,omitempty"`
+	Prev         string         `json:"prev,omitempty"`
+}
+
+// Problem is an RFC 7807 problem details document, sent with the
+// application/problem+json content type for every error response
+type Problem struct {
+	// Type is a stable URI identifying the kind of problem; clients should
+	// branch on it rather than on Title or Detail
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// InvalidParams lists the query or path parameters that failed validation
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam names a request parameter and why it was rejected
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
 }
@@ -0,0 +1,212 @@
+// Package omdbfake is a local stand-in for the OMDb API. It answers t=, i=
+// and s= queries (including Season/Episode, y, type and page) from JSON
+// fixtures so the service can be exercised without spending API quota.
+package omdbfake
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go-api/models"
+)
+
+// pageSize is the fixed number of results OMDb returns per search page
+const pageSize = 10
+
+//go:embed fixtures/default.json
+var defaultFixtures embed.FS
+
+// Fixtures is the data set served by the fake
+type Fixtures struct {
+	Movies   []models.OMDbResponse `json:"movies"`
+	Episodes []Episode             `json:"episodes"`
+}
+
+// Episode is an episode record together with the title of its series
+type Episode struct {
+	Series string `json:"series"`
+	models.OMDbResponse
+}
+
+// DefaultFixtures returns the fixture set bundled with the package
+func DefaultFixtures() (*Fixtures, error) {
+	data, err := defaultFixtures.ReadFile("fixtures/default.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundled fixtures: %w", err)
+	}
+	return ParseFixtures(data)
+}
+
+// LoadFixtures reads a fixture set from a JSON file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	return ParseFixtures(data)
+}
+
+// ParseFixtures decodes a JSON fixture set
+func ParseFixtures(data []byte) (*Fixtures, error) {
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures: %w", err)
+	}
+	for i := range fixtures.Movies {
+		fixtures.Movies[i].Response = "True"
+	}
+	for i := range fixtures.Episodes {
+		fixtures.Episodes[i].Response = "True"
+	}
+	return &fixtures, nil
+}
+
+// Handler serves OMDb-compatible responses from a fixture set
+type Handler struct {
+	fixtures *Fixtures
+
+	// APIKey, when set, must match the apikey parameter of every request
+	APIKey string
+}
+
+func NewHandler(fixtures *Fixtures) *Handler {
+	return &Handler{fixtures: fixtures}
+}
+
+// NewServer starts an httptest server backed by the given fixtures. The
+// caller must Close it.
+func NewServer(fixtures *Fixtures) *httptest.Server {
+	return httptest.NewServer(NewHandler(fixtures))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if h.APIKey != "" && query.Get("apikey") != h.APIKey {
+		writeJSON(w, http.StatusUnauthorized, errorBody("Invalid API key!"))
+		return
+	}
+
+	switch {
+	case query.Get("i") != "":
+		h.byID(w, query.Get("i"))
+	case query.Get("t") != "":
+		h.byTitle(w, query)
+	case query.Get("s") != "":
+		h.search(w, query)
+	default:
+		writeJSON(w, http.StatusOK, errorBody("Incorrect IMDb ID."))
+	}
+}
+
+func (h *Handler) byID(w http.ResponseWriter, id string) {
+	for _, movie := range h.fixtures.Movies {
+		if movie.ImdbID == id {
+			writeJSON(w, http.StatusOK, movie)
+			return
+		}
+	}
+	for _, episode := range h.fixtures.Episodes {
+		if episode.ImdbID == id {
+			writeJSON(w, http.StatusOK, episode.OMDbResponse)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, errorBody("Incorrect IMDb ID."))
+}
+
+func (h *Handler) byTitle(w http.ResponseWriter, query url.Values) {
+	title := query.Get("t")
+	season, episode := query.Get("Season"), query.Get("Episode")
+
+	if season != "" && episode != "" {
+		for _, ep := range h.fixtures.Episodes {
+			if strings.EqualFold(ep.Series, title) && ep.Season == season && ep.Episode == episode {
+				writeJSON(w, http.StatusOK, ep.OMDbResponse)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, errorBody("Series or episode not found!"))
+		return
+	}
+
+	for _, movie := range h.fixtures.Movies {
+		if strings.EqualFold(movie.Title, title) && matchesFilters(movie, query) {
+			writeJSON(w, http.StatusOK, movie)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, errorBody("Movie not found!"))
+}
+
+func (h *Handler) search(w http.ResponseWriter, query url.Values) {
+	term := strings.ToLower(query.Get("s"))
+
+	var results []models.SearchResult
+	for _, movie := range h.fixtures.Movies {
+		if !strings.Contains(strings.ToLower(movie.Title), term) || !matchesFilters(movie, query) {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			Title:  movie.Title,
+			Year:   movie.Year,
+			ImdbID: movie.ImdbID,
+			Type:   movie.Type,
+			Poster: movie.Poster,
+		})
+	}
+
+	page := 1
+	if p := query.Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 100 {
+			writeJSON(w, http.StatusOK, errorBody("Movie not found!"))
+			return
+		}
+		page = n
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(results) {
+		writeJSON(w, http.StatusOK, errorBody("Movie not found!"))
+		return
+	}
+	end := min(start+pageSize, len(results))
+
+	writeJSON(w, http.StatusOK, models.SearchResponse{
+		Search:       results[start:end],
+		TotalResults: strconv.Itoa(len(results)),
+		Response:     "True",
+	})
+}
+
+// matchesFilters applies the optional y and type parameters
+func matchesFilters(movie models.OMDbResponse, query url.Values) bool {
+	if year := query.Get("y"); year != "" && !strings.HasPrefix(movie.Year, year) {
+		return false
+	}
+	if kind := query.Get("type"); kind != "" && !strings.EqualFold(movie.Type, kind) {
+		return false
+	}
+	return true
+}
+
+func errorBody(message string) map[string]string {
+	return map[string]string{
+		"Response": "False",
+		"Error":    message,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through while counting failures
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen fails every call immediately until OpenDuration has passed
+	BreakerOpen
+
+	// BreakerHalfOpen lets a few probe calls through to test whether the
+	// upstream has recovered
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerConfig sets when a CircuitBreaker trips and how it recovers
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed calls that opens
+	// the breaker
+	FailureThreshold int
+
+	// OpenDuration is how long the breaker stays open before probing
+	OpenDuration time.Duration
+
+	// HalfOpenProbes is the number of calls let through at once while half
+	// open. One success closes the breaker; one failure opens it again.
+	HalfOpenProbes int
+}
+
+// BreakerStatus is a snapshot of a CircuitBreaker for health reporting
+type BreakerStatus struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenedAt            time.Time `json:"opened_at,omitzero"`
+	RetryAt             time.Time `json:"retry_at,omitzero"`
+}
+
+// CircuitBreaker stops calls to OMDb after repeated failures so that an
+// outage is answered immediately instead of by a pile of doomed requests.
+// Only transport errors, timeouts and 5xx answers count as failures; OMDb
+// answering "not found" shows it is up.
+type CircuitBreaker struct {
+	config BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int // consecutive failures while closed
+	openedAt time.Time
+	probes   int // probe calls in flight while half open
+}
+
+func NewCircuitBreaker(config BreakerConfig) *CircuitBreaker {
+	config.FailureThreshold = max(config.FailureThreshold, 1)
+	config.HalfOpenProbes = max(config.HalfOpenProbes, 1)
+	return &CircuitBreaker{config: config}
+}
+
+// WithCircuitBreaker guards upstream calls with breaker
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(s *OMDbService) {
+		s.Breaker = breaker
+	}
+}
+
+// acquire asks to make a call. It returns an error wrapping ErrCircuitOpen
+// if the call must not be made, and otherwise a function that must be
+// called once the call is over. called reports whether an HTTP exchange
+// with OMDb took place; when none did, a probe slot is given back without
+// recording anything, since nothing was learned about the upstream.
+func (b *CircuitBreaker) acquire() (done func(called bool, err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.rejectLocked(); err != nil {
+		return nil, err
+	}
+
+	probe := b.state == BreakerHalfOpen
+	if probe {
+		b.probes++
+	}
+	return func(called bool, err error) { b.record(probe, called, err) }, nil
+}
+
+// reject returns the error acquire would fail with right now, without
+// taking a probe slot
+func (b *CircuitBreaker) reject() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.config.OpenDuration {
+		return nil
+	}
+	return b.rejectLocked()
+}
+
+// rejectLocked moves an open breaker whose time is up to half open and
+// reports whether a call may proceed. b.mu must be held.
+func (b *CircuitBreaker) rejectLocked() error {
+	if b.state == BreakerOpen {
+		remaining := b.config.OpenDuration - time.Since(b.openedAt)
+		if remaining > 0 {
+			return &UpstreamError{Kind: ErrCircuitOpen, RetryAfter: remaining}
+		}
+		b.state = BreakerHalfOpen
+		b.probes = 0
+	}
+	if b.state == BreakerHalfOpen && b.probes >= b.config.HalfOpenProbes {
+		return &UpstreamError{Kind: ErrCircuitOpen}
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) record(probe, called bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probes--
+	}
+
+	switch {
+	case !called:
+		// No call was made, for instance because every key is quarantined
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrQuotaExceeded):
+		// Our caller gave up, or the call was refused for quota; neither
+		// says anything about whether the upstream is up
+	case errors.Is(err, ErrUpstreamUnavailable), errors.Is(err, ErrUpstreamTimeout):
+		b.failures++
+		if (b.state == BreakerHalfOpen && probe) || (b.state == BreakerClosed && b.failures >= b.config.FailureThreshold) {
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	default:
+		// While half open only the probes decide; calls that started before
+		// the breaker tripped say nothing about the upstream now
+		if b.state == BreakerClosed || (b.state == BreakerHalfOpen && probe) {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+	}
+}
+
+// Status returns a snapshot of the breaker
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == BreakerOpen {
+		status.OpenedAt = b.openedAt
+		status.RetryAt = b.openedAt.Add(b.config.OpenDuration)
+	}
+	return status
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var errUnavailable = &UpstreamError{Kind: ErrUpstreamUnavailable}
+
+// callThrough acquires b and records a call that ended with err
+func callThrough(t *testing.T, b *CircuitBreaker, err error) {
+	t.Helper()
+	done, acquireErr := b.acquire()
+	if acquireErr != nil {
+		t.Fatalf("acquire: %v", acquireErr)
+	}
+	done(true, err)
+}
+
+// tripBreaker opens b and waits for its open period to run out
+func tripBreaker(t *testing.T, b *CircuitBreaker) {
+	t.Helper()
+	for range b.config.FailureThreshold {
+		callThrough(t, b, errUnavailable)
+	}
+	if state := b.Status().State; state != "open" {
+		t.Fatalf("breaker is %s after tripping, want open", state)
+	}
+	time.Sleep(b.config.OpenDuration + 5*time.Millisecond)
+}
+
+func TestBreakerTripsAtFailureThreshold(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{FailureThreshold: 3, OpenDuration: time.Minute})
+
+	callThrough(t, b, errUnavailable)
+	callThrough(t, b, &UpstreamError{Kind: ErrUpstreamTimeout})
+	if status := b.Status(); status.State != "closed" || status.ConsecutiveFailures != 2 {
+		t.Fatalf("status = %+v, want closed with 2 failures", status)
+	}
+
+	// A success resets the count
+	callThrough(t, b, nil)
+	callThrough(t, b, errUnavailable)
+	callThrough(t, b, errUnavailable)
+	if state := b.Status().State; state != "closed" {
+		t.Fatalf("breaker is %s after 2 failures following a success, want closed", state)
+	}
+
+	callThrough(t, b, errUnavailable)
+	if state := b.Status().State; state != "open" {
+		t.Errorf("breaker is %s after 3 failures, want open", state)
+	}
+}
+
+func TestBreakerFailsFastWhileOpen(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: time.Minute})
+	callThrough(t, b, errUnavailable)
+
+	_, err := b.acquire()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("acquire while open: %v, want ErrCircuitOpen", err)
+	}
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("acquire while open returned %T, want *UpstreamError", err)
+	}
+	if upstreamErr.RetryAfter <= 0 || upstreamErr.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want within the open duration", upstreamErr.RetryAfter)
+	}
+	if err := b.reject(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("reject while open: %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestBreakerLimitsHalfOpenProbes(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: 10 * time.Millisecond, HalfOpenProbes: 2})
+	tripBreaker(t, b)
+
+	if err := b.reject(); err != nil {
+		t.Fatalf("reject once the open period is over: %v", err)
+	}
+
+	var probes []func(bool, error)
+	for i := range 2 {
+		done, err := b.acquire()
+		if err != nil {
+			t.Fatalf("probe %d: %v", i, err)
+		}
+		probes = append(probes, done)
+	}
+	if state := b.Status().State; state != "half-open" {
+		t.Fatalf("breaker is %s, want half-open", state)
+	}
+
+	_, err := b.acquire()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("third probe: %v, want ErrCircuitOpen", err)
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter != 0 {
+		t.Errorf("RetryAfter = %v while half open, want 0", upstreamErr.RetryAfter)
+	}
+
+	// A probe that made no call gives its slot back
+	probes[0](false, nil)
+	if _, err := b.acquire(); err != nil {
+		t.Errorf("acquire after a probe gave its slot back: %v", err)
+	}
+}
+
+func TestBreakerProbeSuccessCloses(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{FailureThreshold: 2, OpenDuration: 10 * time.Millisecond})
+
+	// A call that started before the breaker tripped is not a probe
+	stale, err := b.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tripBreaker(t, b)
+	if err := b.reject(); err != nil {
+		t.Fatalf("reject once the open period is over: %v", err)
+	}
+	stale(true, nil)
+	if state := b.Status().State; state != "open" {
+		t.Fatalf("breaker is %s after a stale success, want it still waiting for a probe", state)
+	}
+
+	callThrough(t, b, nil)
+	if status := b.Status(); status.State != "closed" || status.ConsecutiveFailures != 0 {
+		t.Errorf("status after a successful probe = %+v, want closed with no failures", status)
+	}
+}
+
+func TestBreakerProbeFailureReopens(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{FailureThreshold: 3, OpenDuration: 10 * time.Millisecond})
+	tripBreaker(t, b)
+
+	callThrough(t, b, &UpstreamError{Kind: ErrUpstreamTimeout})
+	status := b.Status()
+	if status.State != "open" {
+		t.Fatalf("breaker is %s after a failed probe, want open", status.State)
+	}
+	if !status.RetryAt.After(time.Now()) {
+		t.Errorf("RetryAt = %v, want a new open period", status.RetryAt)
+	}
+	if _, err := b.acquire(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("acquire after a failed probe: %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestBreakerIgnoresCancellationsAndQuota(t *testing.T) {
+	ignored := []error{
+		context.Canceled,
+		context.DeadlineExceeded,
+		fmt.Errorf("search: %w", context.Canceled),
+		ErrQuotaExceeded,
+	}
+
+	b := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: 10 * time.Millisecond})
+	for _, err := range ignored {
+		callThrough(t, b, err)
+		if status := b.Status(); status.State != "closed" || status.ConsecutiveFailures != 0 {
+			t.Errorf("after %v: status = %+v, want closed with no failures", err, status)
+		}
+	}
+
+	// Nor do they settle a probe either way
+	tripBreaker(t, b)
+	for _, err := range ignored {
+		callThrough(t, b, err)
+		if state := b.Status().State; state != "half-open" {
+			t.Errorf("after a probe ending in %v: breaker is %s, want half-open", err, state)
+		}
+	}
+}
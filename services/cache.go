@@ -0,0 +1,187 @@
+package services
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestKind classifies upstream OMDb calls
+type requestKind string
+
+const (
+	kindDetails requestKind = "details" // t= and i= lookups
+	kindSearch  requestKind = "search"  // s= lookups
+	kindEpisode requestKind = "episode" // t= lookups with Season and Episode
+)
+
+// CacheConfig sizes a ResponseCache and sets how long each kind of response
+// stays fresh. A zero TTL disables caching for that kind.
+type CacheConfig struct {
+	// MaxEntries bounds the cache; the least recently used entry is evicted
+	// once it is full
+	MaxEntries int
+
+	DetailsTTL time.Duration
+	SearchTTL  time.Duration
+	EpisodeTTL time.Duration
+
+	// NegativeTTL is how long "not found" answers are remembered
+	NegativeTTL time.Duration
+}
+
+// CacheStats are the counters of a ResponseCache
+type CacheStats struct {
+	Hits      map[string]uint64 `json:"hits"`
+	Misses    map[string]uint64 `json:"misses"`
+	Evictions uint64            `json:"evictions"`
+	Entries   int               `json:"entries"`
+}
+
+// ResponseCache is a size-bounded LRU cache of raw OMDb response bodies,
+// keyed on the normalized query parameters of the request minus the API key
+type ResponseCache struct {
+	config CacheConfig
+
+	mu        sync.Mutex
+	order     *list.List // front is most recently used
+	entries   map[string]*list.Element
+	hits      map[requestKind]uint64
+	misses    map[requestKind]uint64
+	evictions uint64
+}
+
+type cacheEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func NewResponseCache(config CacheConfig) *ResponseCache {
+	return &ResponseCache{
+		config:  config,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		hits:    make(map[requestKind]uint64),
+		misses:  make(map[requestKind]uint64),
+	}
+}
+
+// get returns the cached body for a request, counting the hit or miss
+func (c *ResponseCache) get(kind requestKind, params url.Values) ([]byte, bool) {
+	key := cacheKey(kind, params)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if ok && time.Now().After(element.Value.(*cacheEntry).expires) {
+		c.removeElement(element)
+		ok = false
+	}
+	if !ok {
+		c.misses[kind]++
+		return nil, false
+	}
+
+	c.hits[kind]++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).body, true
+}
+
+// put stores an upstream response body. Successful responses are kept for
+// the TTL of their kind and "not found" answers for NegativeTTL; any other
+// OMDb error, such as an exhausted quota, is not cached.
+func (c *ResponseCache) put(kind requestKind, params url.Values, body []byte) {
+	ttl := c.ttlFor(kind, body)
+	if ttl <= 0 || c.config.MaxEntries <= 0 {
+		return
+	}
+
+	key := cacheKey(kind, params)
+	entry := &cacheEntry{key: key, body: body, expires: time.Now().Add(ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.config.MaxEntries {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Stats returns a snapshot of the cache counters keyed by request kind
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Hits:      make(map[string]uint64, len(c.hits)),
+		Misses:    make(map[string]uint64, len(c.misses)),
+		Evictions: c.evictions,
+		Entries:   c.order.Len(),
+	}
+	for kind, n := range c.hits {
+		stats.Hits[string(kind)] = n
+	}
+	for kind, n := range c.misses {
+		stats.Misses[string(kind)] = n
+	}
+	return stats
+}
+
+func (c *ResponseCache) ttlFor(kind requestKind, body []byte) time.Duration {
+	var status struct {
+		Response string `json:"Response"`
+		Error    string `json:"Error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return 0
+	}
+
+	if status.Response == "False" {
+		if isNotFoundMessage(status.Error) {
+			return c.config.NegativeTTL
+		}
+		return 0
+	}
+
+	switch kind {
+	case kindSearch:
+		return c.config.SearchTTL
+	case kindEpisode:
+		return c.config.EpisodeTTL
+	default:
+		return c.config.DetailsTTL
+	}
+}
+
+func (c *ResponseCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// cacheKey normalizes the request so that lookups differing only in API key,
+// parameter order, letter case or surrounding whitespace share an entry
+func cacheKey(kind requestKind, params url.Values) string {
+	normalized := url.Values{}
+	for key, values := range params {
+		if key == "apikey" {
+			continue
+		}
+		for _, value := range values {
+			normalized.Add(strings.ToLower(key), strings.ToLower(strings.TrimSpace(value)))
+		}
+	}
+	return string(kind) + "?" + normalized.Encode()
+}
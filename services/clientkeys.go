@@ -0,0 +1,246 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes a client key may be granted, one per route group
+const (
+	ScopeAPI   = "api"
+	ScopeAdmin = "admin"
+)
+
+// clientKeyReload is how often the key file is checked for changes made by
+// the apikeys command while the server is running
+const clientKeyReload = 2 * time.Second
+
+// ErrClientKeyNotFound means no client key has the given ID
+var ErrClientKeyNotFound = errors.New("client key not found")
+
+// Client is the identity behind an authenticated request
+type Client struct {
+	ID     string
+	Name   string
+	Scopes []string
+}
+
+// HasScope reports whether the client was granted scope
+func (c *Client) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// ClientKeyInfo describes a client key without its secret
+type ClientKeyInfo struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ClientKeyStore holds the API keys clients use to call this service,
+// persisted to a JSON file. Keys have the form <id>.<secret>; only a
+// SHA-256 hash of the secret is stored, so the file does not reveal usable
+// keys. Changes made to the file by another process are picked up within
+// a couple of seconds.
+type ClientKeyStore struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string]clientKeyRecord
+	modTime time.Time
+	checked time.Time
+}
+
+type clientKeyRecord struct {
+	ClientKeyInfo
+	Hash string `json:"hash"`
+}
+
+// OpenClientKeyStore loads the key file at path, which need not exist yet
+func OpenClientKeyStore(path string) (*ClientKeyStore, error) {
+	s := &ClientKeyStore{path: path, keys: make(map[string]clientKeyRecord)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Authenticate returns the client a presented key belongs to, if the key
+// exists and has not been revoked
+func (s *ClientKeyStore) Authenticate(presented string) (*Client, bool) {
+	id, secret, ok := strings.Cut(presented, ".")
+	if !ok {
+		return nil, false
+	}
+
+	s.reloadIfChanged()
+
+	s.mu.RLock()
+	record, ok := s.keys[id]
+	s.mu.RUnlock()
+	if !ok || record.RevokedAt != nil {
+		return nil, false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(record.Hash)) != 1 {
+		return nil, false
+	}
+	return &Client{ID: record.ID, Name: record.Name, Scopes: record.Scopes}, true
+}
+
+// Create issues a new key. The returned key is the only copy of its secret.
+func (s *ClientKeyStore) Create(name string, scopes []string) (string, ClientKeyInfo, error) {
+	idBytes := make([]byte, 4)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", ClientKeyInfo{}, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", ClientKeyInfo{}, err
+	}
+	id := hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	record := clientKeyRecord{
+		ClientKeyInfo: ClientKeyInfo{
+			ID:        id,
+			Name:      name,
+			Scopes:    scopes,
+			CreatedAt: time.Now().UTC(),
+		},
+		Hash: hashSecret(secret),
+	}
+
+	err := s.update(func(keys map[string]clientKeyRecord) error {
+		if _, taken := keys[id]; taken {
+			return errors.New("generated a duplicate key ID; try again")
+		}
+		keys[id] = record
+		return nil
+	})
+	if err != nil {
+		return "", ClientKeyInfo{}, err
+	}
+	return id + "." + secret, record.ClientKeyInfo, nil
+}
+
+// Revoke disables a key. Revoked keys stay in the file for the record.
+func (s *ClientKeyStore) Revoke(id string) error {
+	return s.update(func(keys map[string]clientKeyRecord) error {
+		record, ok := keys[id]
+		if !ok {
+			return ErrClientKeyNotFound
+		}
+		if record.RevokedAt == nil {
+			now := time.Now().UTC()
+			record.RevokedAt = &now
+			keys[id] = record
+		}
+		return nil
+	})
+}
+
+// List returns every key, oldest first
+func (s *ClientKeyStore) List() []ClientKeyInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]ClientKeyInfo, 0, len(s.keys))
+	for _, record := range s.keys {
+		infos = append(infos, record.ClientKeyInfo)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// update applies fn to the latest keys on disk and writes the result back
+func (s *ClientKeyStore) update(fn func(keys map[string]clientKeyRecord) error) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := fn(s.keys); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write client keys: %w", err)
+	}
+	return nil
+}
+
+// reloadIfChanged reloads the file if it changed since it was last read,
+// checking at most every clientKeyReload. A file that fails to load keeps
+// the keys already in memory.
+func (s *ClientKeyStore) reloadIfChanged() {
+	s.mu.Lock()
+	due := time.Since(s.checked) >= clientKeyReload
+	if due {
+		s.checked = time.Now()
+	}
+	s.mu.Unlock()
+
+	if due {
+		s.load()
+	}
+}
+
+func (s *ClientKeyStore) load() error {
+	info, err := os.Stat(s.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read client keys: %w", err)
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read client keys: %w", err)
+	}
+	keys := make(map[string]clientKeyRecord)
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to parse client keys: %w", err)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientKeyStoreCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "client_keys.json")
+	s, err := OpenClientKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, info, err := s.Create("frontend", []string{ScopeAPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, secret, ok := strings.Cut(key, ".")
+	if !ok || id != info.ID || secret == "" {
+		t.Fatalf("key %q does not have the form %s.<secret>", key, info.ID)
+	}
+
+	client, ok := s.Authenticate(key)
+	if !ok || client.ID != info.ID || client.Name != "frontend" || !client.HasScope(ScopeAPI) || client.HasScope(ScopeAdmin) {
+		t.Errorf("Authenticate = %+v, %v, want the frontend client with the api scope", client, ok)
+	}
+
+	// Only a hash of the secret is written
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("key file contains the secret: %s", data)
+	}
+	if !strings.Contains(string(data), hashSecret(secret)) {
+		t.Errorf("key file lacks the hash of the secret: %s", data)
+	}
+}
+
+func TestClientKeyStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client_keys.json")
+	s, err := OpenClientKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontend, _, err := s.Create("frontend", []string{ScopeAPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	partner, partnerInfo, err := s.Create("partner", []string{ScopeAPI, ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(partnerInfo.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenClientKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Authenticate(frontend); !ok {
+		t.Errorf("frontend key not accepted after reopening")
+	}
+	if _, ok := reopened.Authenticate(partner); ok {
+		t.Errorf("revoked partner key accepted after reopening")
+	}
+
+	infos := reopened.List()
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if want := []string{"frontend", "partner"}; !slices.Equal(names, want) {
+		t.Fatalf("List() names = %v, want %v", names, want)
+	}
+	if infos[0].RevokedAt != nil || infos[1].RevokedAt == nil {
+		t.Errorf("revoked at %v and %v, want only the partner key revoked", infos[0].RevokedAt, infos[1].RevokedAt)
+	}
+	if !slices.Equal(infos[1].Scopes, []string{ScopeAPI, ScopeAdmin}) {
+		t.Errorf("partner scopes = %v", infos[1].Scopes)
+	}
+}
+
+func TestClientKeyStorePicksUpOtherWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client_keys.json")
+	server, err := OpenClientKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The apikeys command works on the same file
+	command, err := OpenClientKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, info, err := command.Create("frontend", []string{ScopeAPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip the wait between checks of the file
+	server.mu.Lock()
+	server.checked = time.Time{}
+	server.mu.Unlock()
+	if _, ok := server.Authenticate(key); !ok {
+		t.Fatalf("key created by another writer not accepted")
+	}
+
+	// A change made through the server keeps the other writer's key
+	if _, _, err := server.Create("ops", []string{ScopeAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	if err := command.Revoke(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	server.checked = time.Time{}
+	server.mu.Unlock()
+	if _, ok := server.Authenticate(key); ok {
+		t.Errorf("key revoked by another writer still accepted")
+	}
+	if n := len(server.List()); n != 2 {
+		t.Errorf("server lists %d keys, want 2", n)
+	}
+}
+
+func TestClientKeyStoreRevokeUnknown(t *testing.T) {
+	s, err := OpenClientKeyStore(filepath.Join(t.TempDir(), "client_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke("deadbeef"); !errors.Is(err, ErrClientKeyNotFound) {
+		t.Errorf("Revoke of an unknown key = %v, want ErrClientKeyNotFound", err)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinel errors describing why an OMDb lookup failed. Errors returned by
+// the service wrap one of these; test for them with errors.Is.
+var (
+	// ErrNotFound means OMDb has no title, episode or ID matching the lookup
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidAPIKey means OMDb rejected the configured API key
+	ErrInvalidAPIKey = errors.New("invalid OMDb API key")
+
+	// ErrQuotaExceeded means the API key has used up its request allowance
+	ErrQuotaExceeded = errors.New("OMDb request limit reached")
+
+	// ErrTooManyResults means a search was too broad for OMDb to answer
+	ErrTooManyResults = errors.New("too many results")
+
+	// ErrUpstreamUnavailable means OMDb could not be reached or failed with a server error
+	ErrUpstreamUnavailable = errors.New("OMDb unavailable")
+
+	// ErrUpstreamTimeout means OMDb did not answer within the client timeout
+	ErrUpstreamTimeout = errors.New("OMDb timed out")
+
+	// ErrMalformedResponse means OMDb answered with something that is not a valid response
+	ErrMalformedResponse = errors.New("malformed OMDb response")
+
+	// ErrCircuitOpen means the call was not made because recent OMDb calls
+	// kept failing and the circuit breaker is open
+	ErrCircuitOpen = errors.New("OMDb circuit breaker open")
+)
+
+// UpstreamError is a failed OMDb call. Kind is one of the sentinel errors
+// above; Message is OMDb's own Error field when it sent one.
+type UpstreamError struct {
+	Kind       error
+	Message    string
+	StatusCode int
+	Err        error
+
+	// RetryAfter is how long OMDb asked us to wait before calling again
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamError) Error() string {
+	switch {
+	case e.Message != "":
+		return "OMDb API error: " + e.Message
+	case e.Err != nil:
+		return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("%v: HTTP %d", e.Kind, e.StatusCode)
+	default:
+		return e.Kind.Error()
+	}
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// omdbError converts the Error field of a "Response": "False" body, and
+// the HTTP status it came with, into an UpstreamError
+func omdbError(message string, statusCode int) *UpstreamError {
+	kind := classifyMessage(message)
+	if kind == nil {
+		kind = classifyStatus(statusCode)
+	}
+	return &UpstreamError{Kind: kind, Message: message, StatusCode: statusCode}
+}
+
+// classifyMessage maps OMDb's error messages to sentinel errors, returning
+// nil for messages it does not recognize
+func classifyMessage(message string) error {
+	message = strings.ToLower(message)
+	switch {
+	case message == "":
+		return nil
+	case strings.Contains(message, "not found"), strings.Contains(message, "incorrect imdb id"):
+		return ErrNotFound
+	case strings.Contains(message, "api key"):
+		return ErrInvalidAPIKey
+	case strings.Contains(message, "limit reached"):
+		return ErrQuotaExceeded
+	case strings.Contains(message, "too many results"):
+		return ErrTooManyResults
+	}
+	return nil
+}
+
+// classifyStatus maps an HTTP status from OMDb to a sentinel error
+func classifyStatus(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrInvalidAPIKey
+	case statusCode == http.StatusTooManyRequests:
+		return ErrQuotaExceeded
+	case statusCode == http.StatusGatewayTimeout:
+		return ErrUpstreamTimeout
+	case statusCode >= 500:
+		return ErrUpstreamUnavailable
+	}
+	return ErrMalformedResponse
+}
+
+// isNotFoundMessage reports whether an OMDb Error field means the requested
+// title, episode or ID does not exist
+func isNotFoundMessage(message string) bool {
+	return classifyMessage(message) == ErrNotFound
+}
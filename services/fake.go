@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-api/models"
+)
+
+// FakeProvider is an in-memory MovieProvider for tests and local development.
+// It answers every lookup from the records added to it and never touches the network.
+type FakeProvider struct {
+	mu       sync.RWMutex
+	movies   []*models.OMDbResponse
+	episodes map[string]*models.OMDbResponse
+}
+
+func NewFakeProvider(movies ...*models.OMDbResponse) *FakeProvider {
+	f := &FakeProvider{
+		episodes: make(map[string]*models.OMDbResponse),
+	}
+	for _, movie := range movies {
+		f.AddMovie(movie)
+	}
+	return f
+}
+
+// AddMovie registers a movie or series record
+func (f *FakeProvider) AddMovie(movie *models.OMDbResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record := *movie
+	record.Response = "True"
+	f.movies = append(f.movies, &record)
+}
+
+// AddEpisode registers an episode record for the given series, season and episode number
+func (f *FakeProvider) AddEpisode(seriesTitle string, season, episode int, details *models.OMDbResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record := *details
+	record.Response = "True"
+	record.Season = strconv.Itoa(season)
+	record.Episode = strconv.Itoa(episode)
+	f.episodes[episodeKey(seriesTitle, season, episode)] = &record
+}
+
+// GetMovieByTitle returns the first registered record whose title matches case-insensitively
+func (f *FakeProvider) GetMovieByTitle(ctx context.Context, title string) (*models.OMDbResponse, error) {
+	return f.FindTitle(ctx, TitleQuery{Title: title})
+}
+
+// FindTitle returns the first registered record matching the title, year and type of query
+func (f *FakeProvider) FindTitle(ctx context.Context, query TitleQuery) (*models.OMDbResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, movie := range f.movies {
+		if strings.EqualFold(movie.Title, query.Title) && query.matches(movie) {
+			record := *movie
+			return &record, nil
+		}
+	}
+	return nil, omdbError("Movie not found!", http.StatusOK)
+}
+
+// TitleMatches returns every registered record whose title is exactly query.Title
+func (f *FakeProvider) TitleMatches(ctx context.Context, query TitleQuery) ([]models.SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var matches []models.SearchResult
+	for _, movie := range f.movies {
+		if strings.EqualFold(movie.Title, query.Title) && query.matches(movie) {
+			matches = append(matches, searchResult(movie))
+		}
+	}
+	return matches, nil
+}
+
+// GetMovieByID returns the registered movie, series or episode record with the given IMDb ID
+func (f *FakeProvider) GetMovieByID(ctx context.Context, imdbID string) (*models.OMDbResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, movie := range f.movies {
+		if movie.ImdbID == imdbID {
+			record := *movie
+			return &record, nil
+		}
+	}
+	for _, episode := range f.episodes {
+		if episode.ImdbID == imdbID {
+			record := *episode
+			return &record, nil
+		}
+	}
+	return nil, omdbError("Incorrect IMDb ID.", http.StatusOK)
+}
+
+// GetEpisodeDetails returns a registered episode record
+func (f *FakeProvider) GetEpisodeDetails(ctx context.Context, seriesTitle string, season, episode int) (*models.OMDbResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	record, ok := f.episodes[episodeKey(seriesTitle, season, episode)]
+	if !ok {
+		return nil, omdbError("Series or episode not found!", http.StatusOK)
+	}
+	result := *record
+	return &result, nil
+}
+
+// SearchMovies returns registered movies whose title contains the query, paginated like OMDb
+func (f *FakeProvider) SearchMovies(ctx context.Context, query string, page int) (*models.SearchResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var matches []models.SearchResult
+	for _, movie := range f.movies {
+		if movie.Type != "" && movie.Type != "movie" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(movie.Title), strings.ToLower(query)) {
+			matches = append(matches, searchResult(movie))
+		}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * omdbPageSize
+	if start >= len(matches) {
+		return &models.SearchResponse{Response: "False", Error: "Movie not found!"}, nil
+	}
+	end := min(start+omdbPageSize, len(matches))
+
+	return &models.SearchResponse{
+		Search:       matches[start:end],
+		TotalResults: strconv.Itoa(len(matches)),
+		Response:     "True",
+	}, nil
+}
+
+// Search returns a page of registered records whose title contains the query, filtered by type and year.
+// A page below 1 is treated as the first and a page size below 1 as OMDb's.
+func (f *FakeProvider) Search(ctx context.Context, query SearchQuery) (*SearchResults, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	filter := TitleQuery{Year: query.Year, Type: query.Type}
+	var matches []models.SearchResult
+	for _, movie := range f.movies {
+		if strings.Contains(strings.ToLower(movie.Title), strings.ToLower(query.Query)) && filter.matches(movie) {
+			matches = append(matches, searchResult(movie))
+		}
+	}
+
+	page, pageSize := max(query.Page, 1), query.PageSize
+	if pageSize < 1 {
+		pageSize = omdbPageSize
+	}
+	start := (page - 1) * pageSize
+	if start >= len(matches) {
+		return &SearchResults{TotalResults: len(matches)}, nil
+	}
+	end := min(start+pageSize, len(matches))
+
+	return &SearchResults{Results: matches[start:end], TotalResults: len(matches)}, nil
+}
+
+// GetMoviesByGenre returns the highest rated registered movies of a genre
+func (f *FakeProvider) GetMoviesByGenre(ctx context.Context, genre string, limit int) (*GenreResults, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &GenreResults{Movies: f.collect("genre", genre, "", limit)}, nil
+}
+
+// GetRecommendations builds genre, director and actor based recommendations from the registered movies
+func (f *FakeProvider) GetRecommendations(ctx context.Context, favoriteMovie string) (*models.RecommendationsResponse, error) {
+	movieDetails, err := f.GetMovieByTitle(ctx, favoriteMovie)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up favorite movie: %w", err)
+	}
+
+	recommendations := &models.RecommendationsResponse{
+		FavoriteMovie: movieDetails.Title,
+		Recommendations: models.RecommendationsByCategory{
+			GenreBased:    []models.MovieBrief{},
+			DirectorBased: []models.MovieBrief{},
+			ActorBased:    []models.MovieBrief{},
+		},
+	}
+
+	for _, genre := range strings.Split(movieDetails.Genre, ", ") {
+		remaining := 20 - len(recommendations.Recommendations.GenreBased)
+		if remaining <= 0 {
+			break
+		}
+		recommendations.Recommendations.GenreBased = append(recommendations.Recommendations.GenreBased,
+			f.collect("genre", genre, movieDetails.ImdbID, remaining)...)
+	}
+
+	for _, director := range strings.Split(movieDetails.Director, ", ") {
+		remaining := 20 - len(recommendations.Recommendations.DirectorBased)
+		if remaining <= 0 {
+			break
+		}
+		recommendations.Recommendations.DirectorBased = append(recommendations.Recommendations.DirectorBased,
+			f.collect("director", director, movieDetails.ImdbID, remaining)...)
+	}
+
+	actors := strings.Split(movieDetails.Actors, ", ")
+	for _, actor := range actors[:min(3, len(actors))] {
+		remaining := 20 - len(recommendations.Recommendations.ActorBased)
+		if remaining <= 0 {
+			break
+		}
+		recommendations.Recommendations.ActorBased = append(recommendations.Recommendations.ActorBased,
+			f.collect("actor", actor, movieDetails.ImdbID, remaining)...)
+	}
+
+	return recommendations, nil
+}
+
+// collect returns up to limit rated movies matching searchTerm on the searchType field, best rated first
+func (f *FakeProvider) collect(searchType, searchTerm, excludeID string, limit int) []models.MovieBrief {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	term := strings.ToLower(searchTerm)
+	movies := []models.MovieBrief{}
+	for _, movie := range f.movies {
+		if movie.Type != "" && movie.Type != "movie" {
+			continue
+		}
+		if excludeID != "" && movie.ImdbID == excludeID {
+			continue
+		}
+
+		var field string
+		switch searchType {
+		case "genre":
+			field = movie.Genre
+		case "director":
+			field = movie.Director
+		case "actor":
+			field = movie.Actors
+		}
+		if !strings.Contains(strings.ToLower(field), term) {
+			continue
+		}
+
+		if rating, _ := strconv.ParseFloat(movie.ImdbRating, 64); rating > 0 {
+			movies = append(movies, models.MovieBrief{
+				ImdbID:     movie.ImdbID,
+				Title:      movie.Title,
+				Year:       movie.Year,
+				ImdbRating: movie.ImdbRating,
+				Genre:      movie.Genre,
+				Director:   movie.Director,
+				Plot:       movie.Plot,
+			})
+		}
+	}
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		ratingI, _ := strconv.ParseFloat(movies[i].ImdbRating, 64)
+		ratingJ, _ := strconv.ParseFloat(movies[j].ImdbRating, 64)
+		return ratingI > ratingJ
+	})
+
+	if len(movies) > limit {
+		movies = movies[:limit]
+	}
+	return movies
+}
+
+// matches applies the optional year and type of a query to a record
+func (q TitleQuery) matches(movie *models.OMDbResponse) bool {
+	if q.Year != "" && !strings.HasPrefix(movie.Year, q.Year) {
+		return false
+	}
+	if q.Type != "" && !strings.EqualFold(movie.Type, q.Type) {
+		return false
+	}
+	return true
+}
+
+func searchResult(movie *models.OMDbResponse) models.SearchResult {
+	return models.SearchResult{
+		Title:  movie.Title,
+		Year:   movie.Year,
+		ImdbID: movie.ImdbID,
+		Type:   movie.Type,
+		Poster: movie.Poster,
+	}
+}
+
+func episodeKey(seriesTitle string, season, episode int) string {
+	return fmt.Sprintf("%s|%d|%d", strings.ToLower(seriesTitle), season, episode)
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"go-api/models"
+)
+
+func TestFakeProviderSearchNormalizesPaging(t *testing.T) {
+	var movies []*models.OMDbResponse
+	for _, id := range []string{"tt0000001", "tt0000002", "tt0000003"} {
+		movies = append(movies, &models.OMDbResponse{Title: "Dune", ImdbID: id, Type: "movie"})
+	}
+	f := NewFakeProvider(movies...)
+
+	tests := []struct {
+		page, pageSize int
+		want           int
+	}{
+		{0, 2, 2},
+		{-1, 2, 2},
+		{1, 0, 3},
+		{2, -5, 0},
+	}
+	for _, tt := range tests {
+		results, err := f.Search(context.Background(), SearchQuery{Query: "dune", Page: tt.page, PageSize: tt.pageSize})
+		if err != nil {
+			t.Fatalf("page %d, page size %d: %v", tt.page, tt.pageSize, err)
+		}
+		if len(results.Results) != tt.want || results.TotalResults != 3 {
+			t.Errorf("page %d, page size %d: got %d results of %d, want %d of 3", tt.page, tt.pageSize, len(results.Results), results.TotalResults, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"go-api/models"
+)
+
+// defaultConcurrency bounds the upstream calls a single fan-out makes at once
+const defaultConcurrency = 8
+
+// fanOutSearch searches each term over the given number of pages, looks up
+// the details of every distinct result and returns, in search order, up to
+// want rated movies accepted by keep. The result with IMDb ID excludeID, if
+// any, is skipped. Details are fetched by IMDb ID so remakes sharing a title
+// are kept apart.
+//
+// The pages of a term and then the detail lookups for its results run
+// concurrently on at most s.Concurrency goroutines. Terms are handled one at
+// a time so later terms are only searched when earlier ones came up short.
+// Results are collected by index, so the output does not depend on the order
+// in which upstream calls complete. If ctx is done part way through, the
+// movies found so far are returned with ctx.Err().
+//
+// Upstream calls that fail even after retrying are skipped; failed counts
+// them so callers can tell a short list from a complete one.
+func (s *OMDbService) fanOutSearch(ctx context.Context, terms []string, pages, want int, excludeID string, keep func(*models.OMDbResponse) bool) (movies []models.MovieBrief, failed int, err error) {
+	seen := make(map[string]bool) // To avoid duplicates; only touched by this goroutine
+	var failures atomic.Int64
+
+	for _, term := range terms {
+		if len(movies) >= want || ctx.Err() != nil {
+			break
+		}
+
+		// Search every page of the term at once
+		pageResults := make([][]models.SearchResult, pages)
+		s.forEach(ctx, pages, func(i int) {
+			searchResp, err := s.SearchMovies(ctx, term, i+1)
+			if err == nil && searchResp.Response == "False" {
+				err = omdbError(searchResp.Error, http.StatusOK)
+			}
+			if err != nil {
+				if failedLookup(ctx, err) {
+					failures.Add(1)
+				}
+				return
+			}
+			pageResults[i] = searchResp.Search
+		})
+
+		// Keep the first occurrence of each movie, in page order
+		var candidates []models.SearchResult
+		for _, results := range pageResults {
+			for _, result := range results {
+				if seen[result.ImdbID] || (excludeID != "" && result.ImdbID == excludeID) {
+					continue
+				}
+				seen[result.ImdbID] = true
+				candidates = append(candidates, result)
+			}
+		}
+
+		// Get full movie details for every candidate at once
+		details := make([]*models.OMDbResponse, len(candidates))
+		s.forEach(ctx, len(candidates), func(i int) {
+			movieDetails, err := s.GetMovieByID(ctx, candidates[i].ImdbID)
+			if err != nil {
+				if failedLookup(ctx, err) {
+					failures.Add(1)
+				}
+				return
+			}
+			details[i] = movieDetails
+		})
+
+		for _, movieDetails := range details {
+			if movieDetails == nil || !keep(movieDetails) {
+				continue
+			}
+			// Only include movies with valid ratings
+			if rating, _ := strconv.ParseFloat(movieDetails.ImdbRating, 64); rating <= 0 {
+				continue
+			}
+
+			movies = append(movies, models.MovieBrief{
+				ImdbID:     movieDetails.ImdbID,
+				Title:      movieDetails.Title,
+				Year:       movieDetails.Year,
+				ImdbRating: movieDetails.ImdbRating,
+				Genre:      movieDetails.Genre,
+				Director:   movieDetails.Director,
+				Plot:       movieDetails.Plot,
+			})
+			if len(movies) >= want {
+				break
+			}
+		}
+	}
+
+	return movies, int(failures.Load()), ctx.Err()
+}
+
+// failedLookup reports whether a fan-out call failed, as opposed to finding
+// nothing or being cut short because ctx is done
+func failedLookup(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrTooManyResults)
+}
+
+// forEach calls fn for every index in [0, n) on at most s.Concurrency
+// goroutines and waits for them to return. Once ctx is done no further
+// indexes are handed out.
+func (s *OMDbService) forEach(ctx context.Context, n int, fn func(i int)) {
+	workers := min(max(s.Concurrency, 1), n)
+	if workers == 0 {
+		return
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fanOutWorkers.Inc()
+			defer fanOutWorkers.Dec()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls that share a key into a single
+// execution whose result every caller receives.
+//
+// The execution runs on a context detached from any one caller, so a caller
+// giving up does not fail the others. Once every caller waiting on it has
+// given up the execution is cancelled, and the last caller to leave gets
+// whatever the execution had produced by then together with its own
+// ctx.Err(), preserving partial results when nobody else is waiting.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done    chan struct{}
+	val     T
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (g *flightGroup[T]) do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall[T]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+
+		go func() {
+			defer cancel()
+			call.val, call.err = fn(callCtx)
+
+			g.mu.Lock()
+			g.forget(key, call)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	call.waiters--
+	last := call.waiters == 0
+	if last {
+		// Later callers must start afresh rather than join a cancelled call
+		g.forget(key, call)
+	}
+	g.mu.Unlock()
+
+	if !last {
+		var zero T
+		return zero, ctx.Err()
+	}
+
+	call.cancel()
+	<-call.done
+	return call.val, ctx.Err()
+}
+
+// forget removes call from the group if it is still registered under key.
+// g.mu must be held.
+func (g *flightGroup[T]) forget(key string, call *flightCall[T]) {
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
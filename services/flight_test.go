@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api/omdbfake"
+)
+
+// waitForWaiters blocks until n callers are waiting on the call for key
+func waitForWaiters[T any](t *testing.T, g *flightGroup[T], key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		call := g.calls[key]
+		waiting := call != nil && call.waiters == n
+		g.mu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d callers on %q", n, key)
+}
+
+func TestFlightConcurrentFetchesShareOneCall(t *testing.T) {
+	fixtures, err := omdbfake.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := omdbfake.NewHandler(fixtures)
+	release := make(chan struct{})
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	s := NewOMDbService("test-key", WithBaseURL(server.URL+"/"))
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	titles := make([]string, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			movie, err := s.GetMovieByID(context.Background(), "tt0133093")
+			errs[i] = err
+			if movie != nil {
+				titles[i] = movie.Title
+			}
+		}()
+	}
+
+	waitForWaiters(t, &s.fetches, cacheKey(kindDetails, url.Values{"i": {"tt0133093"}, "plot": {"full"}}), callers)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("OMDb got %d calls, want 1", n)
+	}
+	for i := range callers {
+		if errs[i] != nil || titles[i] != "The Matrix" {
+			t.Errorf("caller %d got %q, %v", i, titles[i], errs[i])
+		}
+	}
+}
+
+func TestFlightWaiterTimingOutDoesNotCancelOthers(t *testing.T) {
+	var g flightGroup[string]
+	release := make(chan struct{})
+	var workErr atomic.Value
+
+	fn := func(ctx context.Context) (string, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			workErr.Store(ctx.Err())
+		}
+		return "done", nil
+	}
+
+	shortCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	type result struct {
+		val string
+		err error
+	}
+	patient := make(chan result, 1)
+	go func() {
+		val, err := g.do(context.Background(), "key", fn)
+		patient <- result{val, err}
+	}()
+	waitForWaiters(t, &g, "key", 1)
+
+	if _, err := g.do(shortCtx, "key", fn); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("impatient caller got %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	got := <-patient
+	if got.val != "done" || got.err != nil {
+		t.Errorf("patient caller got %q, %v", got.val, got.err)
+	}
+	if err := workErr.Load(); err != nil {
+		t.Errorf("shared work was cancelled: %v", err)
+	}
+}
+
+func TestFlightLastWaiterGetsPartialResult(t *testing.T) {
+	var g flightGroup[string]
+	fn := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "partial", ctx.Err()
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.do(firstCtx, "key", fn)
+		first <- err
+	}()
+	waitForWaiters(t, &g, "key", 1)
+
+	// The work sees context.Canceled; the last caller must get its own error
+	lastCtx, cancelLast := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelLast()
+	type result struct {
+		val string
+		err error
+	}
+	last := make(chan result, 1)
+	go func() {
+		val, err := g.do(lastCtx, "key", fn)
+		last <- result{val, err}
+	}()
+	waitForWaiters(t, &g, "key", 2)
+
+	cancelFirst()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller got %v, want context.Canceled", err)
+	}
+
+	// The work keeps running for the caller still waiting
+	select {
+	case got := <-last:
+		t.Fatalf("last caller returned early with %q, %v", got.val, got.err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	got := <-last
+	if got.val != "partial" {
+		t.Errorf("last caller got %q, want the partial result", got.val)
+	}
+	if !errors.Is(got.err, context.DeadlineExceeded) {
+		t.Errorf("last caller got error %v, want its own context.DeadlineExceeded", got.err)
+	}
+}
+
+func TestFlightCallerAfterAbandonedCallStartsAfresh(t *testing.T) {
+	var g flightGroup[string]
+	var runs atomic.Int64
+
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan struct{})
+	go func() {
+		defer close(abandoned)
+		g.do(ctx, "key", func(ctx context.Context) (string, error) {
+			runs.Add(1)
+			<-ctx.Done()
+			return "stale", ctx.Err()
+		})
+	}()
+	waitForWaiters(t, &g, "key", 1)
+	cancel()
+	<-abandoned
+
+	val, err := g.do(context.Background(), "key", func(ctx context.Context) (string, error) {
+		runs.Add(1)
+		return "fresh", nil
+	})
+	if val != "fresh" || err != nil {
+		t.Errorf("got %q, %v, want a fresh result", val, err)
+	}
+	if n := runs.Load(); n != 2 {
+		t.Errorf("work ran %d times, want 2", n)
+	}
+}
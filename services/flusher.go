@@ -0,0 +1,146 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileFlusher writes a snapshot of some in-memory state to a file, in the
+// background at a fixed interval and on close. Its owner marks the state
+// dirty after changing it; a clean state is not written again, and a failed
+// write leaves it dirty so that the next flush tries again.
+type fileFlusher struct {
+	path string
+	name string // what is being written, for errors
+
+	// snapshot encodes the state; it must take whatever lock guards it
+	snapshot func() ([]byte, error)
+
+	mu    sync.Mutex
+	dirty bool
+
+	// flushMu serializes writers of the file
+	flushMu sync.Mutex
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newFileFlusher returns a flusher writing snapshots to path. An empty
+// path makes flushing a no-op. Call start once the state is loaded.
+func newFileFlusher(path, name string, snapshot func() ([]byte, error)) *fileFlusher {
+	return &fileFlusher{
+		path:     path,
+		name:     name,
+		snapshot: snapshot,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// start flushes every interval in the background when interval is
+// positive and there is a file to write
+func (f *fileFlusher) start(interval time.Duration) {
+	if f.path == "" || interval <= 0 {
+		close(f.stopped)
+		return
+	}
+	go f.loop(interval)
+}
+
+// markDirty records that the state changed. Call it after the change, so
+// that a flush racing with it either sees the change or leaves the state
+// dirty.
+func (f *fileFlusher) markDirty() {
+	f.mu.Lock()
+	f.dirty = true
+	f.mu.Unlock()
+}
+
+// flush writes the state to the file if it changed since the last flush
+func (f *fileFlusher) flush() error {
+	if f.path == "" {
+		return nil
+	}
+
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	f.mu.Lock()
+	dirty := f.dirty
+	f.dirty = false
+	f.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+
+	data, err := f.snapshot()
+	if err == nil {
+		err = writeFileAtomic(f.path, data)
+	}
+	if err != nil {
+		// Keep the changes pending so the next flush tries again
+		f.markDirty()
+		return fmt.Errorf("failed to write %s: %w", f.name, err)
+	}
+	return nil
+}
+
+// close stops the background flusher and flushes
+func (f *fileFlusher) close() error {
+	select {
+	case <-f.stop:
+	default:
+		close(f.stop)
+	}
+	<-f.stopped
+	return f.flush()
+}
+
+func (f *fileFlusher) loop(interval time.Duration) {
+	defer close(f.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// A failed flush stays dirty and is retried on the next tick
+			f.flush()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it into place
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeySelection is how a KeyPool chooses among its healthy keys
+type KeySelection string
+
+const (
+	// SelectRoundRobin cycles through the keys in order
+	SelectRoundRobin KeySelection = "round-robin"
+
+	// SelectLeastUsed picks the key that has made the fewest requests
+	SelectLeastUsed KeySelection = "least-used"
+)
+
+// defaultKeyQuarantine is how long a rejected key is left out by default
+const defaultKeyQuarantine = time.Hour
+
+// KeyPoolConfig sets how a KeyPool picks keys and how long it sets aside
+// keys that OMDb rejects
+type KeyPoolConfig struct {
+	Selection KeySelection
+
+	// Quarantine is how long a key OMDb rejected is left out before it is
+	// tried again. Keys that ran out of daily quota are left out until the
+	// quota resets instead.
+	Quarantine time.Duration
+}
+
+// KeyStats are the usage counters of one key. Keys are identified by their
+// position in the configured list, never by their value or anything derived
+// from it.
+type KeyStats struct {
+	Key              string    `json:"key"`
+	Requests         uint64    `json:"requests"`
+	Failures         uint64    `json:"failures"`
+	LastUsed         time.Time `json:"last_used,omitzero"`
+	Quarantined      bool      `json:"quarantined"`
+	QuarantineReason string    `json:"quarantine_reason,omitempty"`
+	QuarantinedUntil time.Time `json:"quarantined_until,omitzero"`
+}
+
+// KeyPool holds the OMDb API keys the service may use. Each upstream call
+// takes a key from the pool; a key that OMDb rejects as invalid or out of
+// quota is quarantined and the call fails over to the next one.
+type KeyPool struct {
+	config KeyPoolConfig
+
+	mu   sync.Mutex
+	keys []*apiKey
+	next int // round-robin position
+}
+
+type apiKey struct {
+	id    string
+	value string
+
+	requests         uint64
+	failures         uint64
+	lastUsed         time.Time
+	quarantineReason error // ErrInvalidAPIKey or ErrQuotaExceeded
+	quarantinedUntil time.Time
+}
+
+// NewKeyPool returns a pool of the given keys, which must not be empty.
+// Duplicate keys are dropped.
+func NewKeyPool(keys []string, config KeyPoolConfig) *KeyPool {
+	if config.Selection == "" {
+		config.Selection = SelectRoundRobin
+	}
+	if config.Quarantine <= 0 {
+		config.Quarantine = defaultKeyQuarantine
+	}
+
+	p := &KeyPool{config: config}
+	seen := make(map[string]bool)
+	for i, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		p.keys = append(p.keys, &apiKey{id: keyLabel(i), value: key})
+	}
+	return p
+}
+
+// keyLabel returns the name by which the key at position i of the
+// configured list is reported in stats, logs and persisted quota counts.
+// OMDb keys are short enough that any unsalted hash of one can be reversed
+// by brute force, so the label is not derived from the key.
+func keyLabel(i int) string {
+	return fmt.Sprintf("key-%d", i+1)
+}
+
+// IDs returns the labels of the keys in the pool
+func (p *KeyPool) IDs() []string {
+	ids := make([]string, len(p.keys))
+	for i, key := range p.keys {
+		ids[i] = key.id
+	}
+	return ids
+}
+
+// Len returns the number of keys in the pool
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+// pick chooses a key that is not quarantined. When every key is, the error
+// wraps the reason of the key that comes back first and says when that is.
+func (p *KeyPool) pick() (*apiKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var chosen, soonest *apiKey
+	for i := range p.keys {
+		key := p.keys[(p.next+i)%len(p.keys)]
+		if now.Before(key.quarantinedUntil) {
+			if soonest == nil || key.quarantinedUntil.Before(soonest.quarantinedUntil) {
+				soonest = key
+			}
+			continue
+		}
+		if chosen == nil || (p.config.Selection == SelectLeastUsed && key.requests < chosen.requests) {
+			chosen = key
+		}
+		if p.config.Selection == SelectRoundRobin {
+			break
+		}
+	}
+
+	if chosen == nil {
+		if soonest == nil {
+			return nil, &UpstreamError{Kind: ErrInvalidAPIKey, Err: errors.New("no OMDb API keys configured")}
+		}
+		return nil, &UpstreamError{
+			Kind:       soonest.quarantineReason,
+			Err:        fmt.Errorf("all %d OMDb API keys are quarantined", len(p.keys)),
+			RetryAfter: soonest.quarantinedUntil.Sub(now),
+		}
+	}
+
+	if p.config.Selection == SelectRoundRobin {
+		for i, key := range p.keys {
+			if key == chosen {
+				p.next = (i + 1) % len(p.keys)
+			}
+		}
+	}
+	chosen.requests++
+	chosen.lastUsed = now
+	return chosen, nil
+}
+
+// quarantine leaves key out until the given time, or for the configured
+// quarantine when until is zero. reason is ErrInvalidAPIKey or
+// ErrQuotaExceeded.
+func (p *KeyPool) quarantine(key *apiKey, reason error, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until.IsZero() {
+		until = time.Now().Add(p.config.Quarantine)
+	}
+	key.failures++
+	key.quarantineReason = reason
+	key.quarantinedUntil = until
+}
+
+// Stats returns the counters of every key in the pool
+func (p *KeyPool) Stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]KeyStats, len(p.keys))
+	for i, key := range p.keys {
+		stats[i] = KeyStats{
+			Key:      key.id,
+			Requests: key.requests,
+			Failures: key.failures,
+			LastUsed: key.lastUsed,
+		}
+		if now.Before(key.quarantinedUntil) {
+			stats[i].Quarantined = true
+			stats[i].QuarantineReason = key.quarantineReason.Error()
+			stats[i].QuarantinedUntil = key.quarantinedUntil
+		}
+	}
+	return stats
+}
+
+// Selection returns how the pool chooses keys
+func (p *KeyPool) Selection() KeySelection {
+	return p.config.Selection
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pickIDs picks n keys from p and returns their labels
+func pickIDs(t *testing.T, p *KeyPool, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range n {
+		key, err := p.pick()
+		if err != nil {
+			t.Fatalf("pick %d: %v", i, err)
+		}
+		ids[i] = key.id
+	}
+	return ids
+}
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	p := NewKeyPool([]string{"a", "b", "c"}, KeyPoolConfig{})
+
+	want := []string{"key-1", "key-2", "key-3", "key-1", "key-2", "key-3"}
+	if got := pickIDs(t, p, 6); !slices.Equal(got, want) {
+		t.Errorf("picked %v, want %v", got, want)
+	}
+
+	p.quarantine(p.keys[1], ErrInvalidAPIKey, time.Time{})
+	want = []string{"key-1", "key-3", "key-1", "key-3"}
+	if got := pickIDs(t, p, 4); !slices.Equal(got, want) {
+		t.Errorf("with key-2 quarantined picked %v, want %v", got, want)
+	}
+}
+
+func TestKeyPoolLeastUsed(t *testing.T) {
+	p := NewKeyPool([]string{"a", "b", "c"}, KeyPoolConfig{Selection: SelectLeastUsed})
+	p.keys[0].requests = 3
+
+	// key-2 and key-3 take turns until they catch up with key-1
+	want := []string{"key-2", "key-3", "key-2", "key-3", "key-2", "key-3", "key-1"}
+	if got := pickIDs(t, p, 7); !slices.Equal(got, want) {
+		t.Errorf("picked %v, want %v", got, want)
+	}
+}
+
+func TestKeyPoolDropsDuplicateKeys(t *testing.T) {
+	p := NewKeyPool([]string{"a", "", "a", "b"}, KeyPoolConfig{})
+
+	// Labels follow the configured positions
+	if got, want := p.IDs(), []string{"key-1", "key-4"}; !slices.Equal(got, want) {
+		t.Errorf("IDs() = %v, want %v", got, want)
+	}
+}
+
+func TestKeyPoolQuarantineExpires(t *testing.T) {
+	p := NewKeyPool([]string{"a"}, KeyPoolConfig{Quarantine: 20 * time.Millisecond})
+	key, err := p.pick()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.quarantine(key, ErrInvalidAPIKey, time.Time{})
+
+	if _, err := p.pick(); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("pick while quarantined: %v, want ErrInvalidAPIKey", err)
+	}
+	if stats := p.Stats(); !stats[0].Quarantined || stats[0].QuarantineReason != ErrInvalidAPIKey.Error() {
+		t.Errorf("stats = %+v, want key-1 quarantined as invalid", stats[0])
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := p.pick(); err != nil {
+		t.Errorf("pick after the quarantine: %v", err)
+	}
+	if stats := p.Stats(); stats[0].Quarantined {
+		t.Errorf("stats = %+v, want key-1 back in use", stats[0])
+	}
+}
+
+func TestKeyPoolAllQuarantined(t *testing.T) {
+	p := NewKeyPool([]string{"a", "b"}, KeyPoolConfig{})
+	now := time.Now()
+	p.quarantine(p.keys[0], ErrInvalidAPIKey, now.Add(time.Hour))
+	p.quarantine(p.keys[1], ErrQuotaExceeded, now.Add(10*time.Minute))
+
+	// The error is that of the key that comes back first
+	_, err := p.pick()
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("pick = %v, want ErrQuotaExceeded", err)
+	}
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("pick returned %T, want *UpstreamError", err)
+	}
+	if upstreamErr.RetryAfter <= 9*time.Minute || upstreamErr.RetryAfter > 10*time.Minute {
+		t.Errorf("RetryAfter = %v, want about 10m", upstreamErr.RetryAfter)
+	}
+	if !strings.Contains(err.Error(), "all 2 OMDb API keys are quarantined") {
+		t.Errorf("error %q does not say every key is quarantined", err)
+	}
+}
+
+func TestKeyPoolFailover(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Query().Get("apikey") {
+		case "revoked":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"Response":"False","Error":"Invalid API key!"}`))
+		case "spent":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"Response":"False","Error":"Request limit reached!"}`))
+		default:
+			w.Write([]byte(matrixBody))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	keys := NewKeyPool([]string{"revoked", "spent", "good"}, KeyPoolConfig{})
+	quota, err := OpenQuotaTracker("", QuotaConfig{KeyIDs: keys.IDs(), DailyLimit: 1000}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewOMDbServiceWithKeys(keys, WithBaseURL(server.URL+"/"), WithQuota(quota))
+
+	if _, err := s.GetMovieByID(context.Background(), "tt0133093"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("OMDb got %d calls, want 3", n)
+	}
+
+	stats := keys.Stats()
+	if !stats[0].Quarantined || stats[0].QuarantineReason != ErrInvalidAPIKey.Error() {
+		t.Errorf("key-1 stats = %+v, want quarantined as invalid", stats[0])
+	}
+	// A key OMDb reports out of quota stays out until the quota resets
+	status := quota.Status()
+	if !stats[1].Quarantined || stats[1].QuarantineReason != ErrQuotaExceeded.Error() || !stats[1].QuarantinedUntil.Equal(status.ResetsAt) {
+		t.Errorf("key-2 stats = %+v, want quarantined for quota until %v", stats[1], status.ResetsAt)
+	}
+	if !status.Keys[1].Exhausted {
+		t.Errorf("quota of key-2 not marked exhausted")
+	}
+
+	// Later calls go straight to the healthy key
+	if _, err := s.GetMovieByID(context.Background(), "tt0234215"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("OMDb got %d calls, want 4", n)
+	}
+}
+
+func TestKeyPoolFailoverRunsOut(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"Response":"False","Error":"Invalid API key!"}`))
+	})
+	s.Keys = NewKeyPool([]string{"a", "b"}, KeyPoolConfig{})
+
+	if _, err := s.GetMovieByID(context.Background(), "tt0133093"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("err = %v, want ErrInvalidAPIKey", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("OMDb got %d calls, want one per key", n)
+	}
+
+	// With every key quarantined OMDb is not called at all
+	if _, err := s.GetMovieByID(context.Background(), "tt0234215"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("err = %v, want ErrInvalidAPIKey", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("OMDb got %d calls, want no more", n)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that spaces out upstream calls. It holds up
+// to burst tokens and refills at perSecond tokens a second; every call takes
+// one, waiting for it if the bucket is empty. Waiters are served in arrival
+// order because each one reserves its token up front.
+type RateLimiter struct {
+	perSecond float64
+	burst     float64
+
+	mu     sync.Mutex
+	tokens float64 // negative while calls are waiting
+	last   time.Time
+}
+
+// NewRateLimiter returns a full bucket. perSecond must be positive.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	burst = max(burst, 1)
+	return &RateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+}
+
+// WithRateLimiter paces upstream calls with limiter
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(s *OMDbService) {
+		s.Limiter = limiter
+	}
+}
+
+// wait blocks until the caller may make a call or ctx is done, in which case
+// the reserved token is handed back and ctx.Err() is returned
+func (l *RateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+	l.last = now
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.perSecond * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForTokens blocks until l holds at most n tokens, that is until enough
+// callers have reserved one
+func waitForTokens(t *testing.T, l *RateLimiter, n float64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		tokens := l.tokens
+		l.mu.Unlock()
+		if tokens <= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for the limiter to fall to %v tokens", n)
+}
+
+func TestRateLimiterServesWaitersInOrder(t *testing.T) {
+	l := NewRateLimiter(20, 1)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	const waiters = 5
+	order := make(chan int, waiters)
+	for i := range waiters {
+		go func() {
+			if err := l.wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+			order <- i
+		}()
+		// Let waiter i take its place in the queue before the next arrives
+		waitForTokens(t, l, -float64(i)-0.5)
+	}
+
+	for want := range waiters {
+		if got := <-order; got != want {
+			t.Fatalf("waiter %d was served in place %d", got, want)
+		}
+	}
+}
+
+func TestRateLimiterReturnsTokenOnCancel(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancelled wait took %v", elapsed)
+	}
+
+	// The cancelled caller's token is back, so the next caller waits for
+	// one token rather than two
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.5 {
+		t.Errorf("tokens = %v after the cancelled wait, want about 0", tokens)
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(1, 3)
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("a full burst took %v, want no wait", elapsed)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Cache statuses reported by lookup and call logs
+const (
+	cacheHit    = "hit"    // answered from the response cache
+	cacheMiss   = "miss"   // fetched from OMDb
+	cacheStored = "stored" // answered from the metadata store
+	cacheOff    = "off"    // no response cache configured
+	cacheBypass = "bypass" // fetched without consulting the cache: store refreshes and readiness probes
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// WithRequestID returns a context carrying a request ID, which is sent to
+// OMDb with every call and added to the context's logger
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, id)
+	return WithLogger(ctx, Logger(ctx).With("request_id", id))
+}
+
+// RequestID returns the request ID set with WithRequestID, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithLogger returns a context whose work is logged to logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// Logger returns the logger set with WithLogger, or the default logger
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// logCall logs one HTTP exchange with OMDb, with the cache status that led
+// to it. The key is identified by its label only.
+func logCall(ctx context.Context, kind requestKind, cache string, key *apiKey, start time.Time, err error) {
+	attrs := []any{
+		"kind", kind,
+		"cache", cache,
+		"key", key.id,
+		"latency_ms", time.Since(start).Milliseconds(),
+		"outcome", outcome(err),
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode != 0 {
+		attrs = append(attrs, "status", upstreamErr.StatusCode)
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	Logger(ctx).Log(ctx, level, "omdb call", attrs...)
+}
+
+// logLookup logs a lookup made by the service, however it was answered.
+// Lookups are logged at debug level since most are cache hits.
+func logLookup(ctx context.Context, kind requestKind, cache string, start time.Time, err error) {
+	Logger(ctx).DebugContext(ctx, "omdb lookup",
+		"kind", kind,
+		"cache", cache,
+		"latency_ms", time.Since(start).Milliseconds(),
+		"outcome", outcome(err),
+	)
+}
+
+// outcomes names the result of a call in logs
+var outcomes = []struct {
+	err  error
+	name string
+}{
+	{context.Canceled, "canceled"},
+	{context.DeadlineExceeded, "deadline_exceeded"},
+	{ErrNotFound, "not_found"},
+	{ErrTooManyResults, "too_many_results"},
+	{ErrInvalidAPIKey, "invalid_api_key"},
+	{ErrQuotaExceeded, "quota_exceeded"},
+	{ErrUpstreamUnavailable, "unavailable"},
+	{ErrUpstreamTimeout, "timeout"},
+	{ErrMalformedResponse, "malformed_response"},
+	{ErrCircuitOpen, "circuit_open"},
+}
+
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, o := range outcomes {
+		if errors.Is(err, o.err) {
+			return o.name
+		}
+	}
+	return "error"
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "omdb_requests_total",
+		Help: "HTTP calls made to OMDb, by lookup kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	upstreamLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "omdb_request_duration_seconds",
+		Help:    "Latency of HTTP calls made to OMDb, by lookup kind.",
+		Buckets: prometheus.ExponentialBuckets(0.025, 2, 10),
+	}, []string{"kind"})
+
+	fanOutWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "omdb_fanout_goroutines",
+		Help: "Goroutines currently running genre and recommendation lookups.",
+	})
+)
+
+// observeCall records one HTTP exchange with OMDb
+func observeCall(kind requestKind, start time.Time, err error) {
+	upstreamCalls.WithLabelValues(string(kind), outcome(err)).Inc()
+	upstreamLatency.WithLabelValues(string(kind)).Observe(time.Since(start).Seconds())
+}
+
+var (
+	cacheHitsDesc = prometheus.NewDesc("omdb_cache_hits_total",
+		"Lookups answered from the response cache, by lookup kind.", []string{"kind"}, nil)
+	cacheMissesDesc = prometheus.NewDesc("omdb_cache_misses_total",
+		"Lookups the response cache could not answer, by lookup kind.", []string{"kind"}, nil)
+	cacheEntriesDesc = prometheus.NewDesc("omdb_cache_entries",
+		"Responses held in the response cache.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("omdb_cache_evictions_total",
+		"Responses evicted from the full response cache.", nil, nil)
+	quotaUsedDesc = prometheus.NewDesc("omdb_quota_used",
+		"OMDb calls made today, by key label (its position in OMDB_API_KEYS).", []string{"key"}, nil)
+	quotaRemainingDesc = prometheus.NewDesc("omdb_quota_remaining",
+		"OMDb calls left today, by key label (its position in OMDB_API_KEYS).", []string{"key"}, nil)
+)
+
+// StatsCollector exports the counters the response cache and quota tracker
+// already keep as Prometheus metrics, read at scrape time. Either may be nil.
+type StatsCollector struct {
+	Cache *ResponseCache
+	Quota *QuotaTracker
+}
+
+func (c StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	if c.Cache != nil {
+		ch <- cacheHitsDesc
+		ch <- cacheMissesDesc
+		ch <- cacheEntriesDesc
+		ch <- cacheEvictionsDesc
+	}
+	if c.Quota != nil {
+		ch <- quotaUsedDesc
+		ch <- quotaRemainingDesc
+	}
+}
+
+func (c StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.Cache != nil {
+		stats := c.Cache.Stats()
+		for kind, n := range stats.Hits {
+			ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(n), kind)
+		}
+		for kind, n := range stats.Misses {
+			ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(n), kind)
+		}
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+		ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	}
+	if c.Quota != nil {
+		for _, key := range c.Quota.Status().Keys {
+			ch <- prometheus.MustNewConstMetric(quotaUsedDesc, prometheus.GaugeValue, float64(key.Used), key.Key)
+			ch <- prometheus.MustNewConstMetric(quotaRemainingDesc, prometheus.GaugeValue, float64(key.Remaining), key.Key)
+		}
+	}
+}
@@ -1,158 +1,341 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"go-api/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// OMDbBaseURL is the public OMDb endpoint used unless WithBaseURL overrides it
 const OMDbBaseURL = "http://www.omdbapi.com/"
 
+// refreshTimeout bounds a background refresh of a stale stored record
+const refreshTimeout = 30 * time.Second
+
+// defaultClientTimeout bounds a single upstream HTTP exchange unless
+// WithHTTPClient supplies a client of its own
+const defaultClientTimeout = 10 * time.Second
+
 type OMDbService struct {
-	APIKey string
-	Client *http.Client
+	// Keys are the API keys upstream calls are made with
+	Keys *KeyPool
+
+	BaseURL string
+	Client  *http.Client
+
+	// Concurrency bounds the upstream calls a single genre or recommendation
+	// fan-out makes at once
+	Concurrency int
+
+	// Cache, when set, answers repeated lookups without an upstream call
+	Cache *ResponseCache
+
+	// Store, when set, persists title and episode records across restarts.
+	// Records older than StoreStaleAfter are still served but refreshed from
+	// upstream in the background.
+	Store           MetadataStore
+	StoreStaleAfter time.Duration
+	refreshing      sync.Map // lookup keys with a background refresh in progress
+	refreshMu       sync.Mutex
+	refreshes       sync.WaitGroup
+	closed          bool // set by Close; no refreshes are started after it
+
+	// Retry controls how transient upstream failures are retried
+	Retry RetryPolicy
+
+	// Breaker, when set, fails upstream calls immediately while OMDb is down
+	Breaker *CircuitBreaker
+
+	// Limiter, when set, paces upstream calls; Quota, when set, counts them
+	// against the daily allowance of the API key
+	Limiter *RateLimiter
+	Quota   *QuotaTracker
+
+	// ProbeInterval is how often readiness checks may call OMDb when no
+	// other call has succeeded within it
+	ProbeInterval time.Duration
+	readiness     readiness
+
+	// Identical concurrent upstream requests and genre/recommendation
+	// computations are coalesced into a single execution
+	fetches         flightGroup[[]byte]
+	genres          flightGroup[*GenreResults]
+	recommendations flightGroup[*models.RecommendationsResponse]
+}
+
+// Option configures an OMDbService
+type Option func(*OMDbService)
+
+// WithBaseURL points the service at a different OMDb-compatible endpoint,
+// such as a staging mirror or the omdbfake server
+func WithBaseURL(baseURL string) Option {
+	return func(s *OMDbService) {
+		s.BaseURL = baseURL
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for upstream calls
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *OMDbService) {
+		s.Client = client
+	}
+}
+
+// WithConcurrency sets how many upstream calls a single fan-out may make at once
+func WithConcurrency(n int) Option {
+	return func(s *OMDbService) {
+		s.Concurrency = n
+	}
+}
+
+// WithCache enables response caching
+func WithCache(cache *ResponseCache) Option {
+	return func(s *OMDbService) {
+		s.Cache = cache
+	}
+}
+
+// WithStore serves title and episode lookups from a persistent store,
+// refreshing records in the background once they are older than staleAfter
+func WithStore(store MetadataStore, staleAfter time.Duration) Option {
+	return func(s *OMDbService) {
+		s.Store = store
+		s.StoreStaleAfter = staleAfter
+	}
+}
+
+// NewOMDbService returns a service calling OMDb with a single API key
+func NewOMDbService(apiKey string, opts ...Option) *OMDbService {
+	return NewOMDbServiceWithKeys(NewKeyPool([]string{apiKey}, KeyPoolConfig{}), opts...)
+}
+
+// NewOMDbServiceWithKeys returns a service calling OMDb with the keys of pool
+func NewOMDbServiceWithKeys(keys *KeyPool, opts ...Option) *OMDbService {
+	s := &OMDbService{
+		Keys:          keys,
+		BaseURL:       OMDbBaseURL,
+		Client:        &http.Client{Timeout: defaultClientTimeout},
+		Concurrency:   defaultConcurrency,
+		ProbeInterval: defaultProbeInterval,
+		Retry: RetryPolicy{
+			MaxAttempts: defaultRetryAttempts,
+			BaseDelay:   defaultRetryBaseDelay,
+			MaxDelay:    defaultRetryMaxDelay,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func NewOMDbService(apiKey string) *OMDbService {
-	return &OMDbService{
-		APIKey: apiKey,
-		Client: &http.Client{},
+// Health reports the state of the circuit breaker, if any
+func (s *OMDbService) Health() UpstreamHealth {
+	var health UpstreamHealth
+	if s.Breaker != nil {
+		status := s.Breaker.Status()
+		health.Breaker = &status
 	}
+	return health
 }
 
 // GetMovieByTitle fetches movie details by title
-func (s *OMDbService) GetMovieByTitle(title string) (*models.OMDbResponse, error) {
+func (s *OMDbService) GetMovieByTitle(ctx context.Context, title string) (*models.OMDbResponse, error) {
+	return s.FindTitle(ctx, TitleQuery{Title: title})
+}
+
+// FindTitle fetches details for a title, narrowed by year and type when given
+func (s *OMDbService) FindTitle(ctx context.Context, query TitleQuery) (*models.OMDbResponse, error) {
 	params := url.Values{}
-	params.Add("apikey", s.APIKey)
-	params.Add("t", title)
+	params.Add("t", query.Title)
+	if query.Year != "" {
+		params.Add("y", query.Year)
+	}
+	if query.Type != "" {
+		params.Add("type", query.Type)
+	}
 	params.Add("plot", "full")
 
-	return s.makeRequest(params)
+	return s.makeRequest(ctx, kindDetails, params)
+}
+
+// TitleMatches returns the search results whose title is exactly
+// query.Title, ignoring case, so callers can tell whether a title lookup is
+// ambiguous. Only the first page of search results is consulted.
+func (s *OMDbService) TitleMatches(ctx context.Context, query TitleQuery) ([]models.SearchResult, error) {
+	searchResp, err := s.search(ctx, query.Title, query.Type, query.Year, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []models.SearchResult
+	for _, result := range searchResp.Search {
+		if strings.EqualFold(result.Title, query.Title) {
+			matches = append(matches, result)
+		}
+	}
+	return matches, nil
+}
+
+// GetMovieByID fetches movie, series or episode details by IMDb ID
+func (s *OMDbService) GetMovieByID(ctx context.Context, imdbID string) (*models.OMDbResponse, error) {
+	params := url.Values{}
+	params.Add("i", imdbID)
+	params.Add("plot", "full")
+
+	return s.makeRequest(ctx, kindDetails, params)
 }
 
 // GetEpisodeDetails fetches TV episode details
-func (s *OMDbService) GetEpisodeDetails(seriesTitle string, season, episode int) (*models.OMDbResponse, error) {
+func (s *OMDbService) GetEpisodeDetails(ctx context.Context, seriesTitle string, season, episode int) (*models.OMDbResponse, error) {
 	params := url.Values{}
-	params.Add("apikey", s.APIKey)
 	params.Add("t", seriesTitle)
 	params.Add("Season", strconv.Itoa(season))
 	params.Add("Episode", strconv.Itoa(episode))
 
-	return s.makeRequest(params)
+	return s.makeRequest(ctx, kindEpisode, params)
 }
 
 // SearchMovies searches for movies by title
-func (s *OMDbService) SearchMovies(query string, page int) (*models.SearchResponse, error) {
+func (s *OMDbService) SearchMovies(ctx context.Context, query string, page int) (*models.SearchResponse, error) {
+	return s.search(ctx, query, "movie", "", page)
+}
+
+// Helper function to run an OMDb search, filtering by type and year when given
+func (s *OMDbService) search(ctx context.Context, query, kind, year string, page int) (*models.SearchResponse, error) {
 	params := url.Values{}
-	params.Add("apikey", s.APIKey)
 	params.Add("s", query)
-	params.Add("type", "movie")
+	if kind != "" {
+		params.Add("type", kind)
+	}
+	if year != "" {
+		params.Add("y", year)
+	}
 	if page > 0 {
 		params.Add("page", strconv.Itoa(page))
 	}
 
-	resp, err := http.Get(OMDbBaseURL + "?" + params.Encode())
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	start := time.Now()
+	body, cache, err := s.fetch(ctx, kindSearch, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		logLookup(ctx, kindSearch, cache, start, err)
+		return nil, err
 	}
 
 	var searchResp models.SearchResponse
 	if err := json.Unmarshal(body, &searchResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		err := &UpstreamError{Kind: ErrMalformedResponse, Err: err}
+		logLookup(ctx, kindSearch, cache, start, err)
+		return nil, err
 	}
 
+	// Callers decide what an unsuccessful search means; the log reports it
+	var lookupErr error
+	if searchResp.Response == "False" {
+		lookupErr = omdbError(searchResp.Error, http.StatusOK)
+	}
+	logLookup(ctx, kindSearch, cache, start, lookupErr)
 	return &searchResp, nil
 }
 
-// GetMoviesByGenre collects movies of a specific genre
-func (s *OMDbService) GetMoviesByGenre(genre string, limit int) ([]models.MovieBrief, error) {
-	var allMovies []models.MovieBrief
-	movieSet := make(map[string]bool) // To avoid duplicates
+// GetMoviesByGenre collects movies of a specific genre. If ctx is done before
+// the search finishes, the movies found so far are returned with ctx.Err().
+// Concurrent calls for the same genre and limit share one computation.
+func (s *OMDbService) GetMoviesByGenre(ctx context.Context, genre string, limit int) (*GenreResults, error) {
+	if err := s.checkQuotaReserve(); err != nil {
+		return nil, err
+	}
 
-	// Search terms that are likely to return movies of the specified genre
-	searchTerms := s.getGenreSearchTerms(genre)
+	key := strings.ToLower(strings.TrimSpace(genre)) + "|" + strconv.Itoa(limit)
+	results, err := s.genres.do(ctx, key, func(ctx context.Context) (*GenreResults, error) {
+		return s.getMoviesByGenre(ctx, genre, limit)
+	})
+	if results == nil {
+		return nil, err
+	}
 
-	for _, term := range searchTerms {
-		if len(allMovies) >= limit*2 { // Get more than needed for better filtering
-			break
-		}
+	// Each caller gets its own copy so it can adjust the list freely
+	return &GenreResults{Movies: slices.Clone(results.Movies), FailedRequests: results.FailedRequests}, err
+}
 
-		// Search multiple pages for each term
-		for page := 1; page <= 3; page++ {
-			searchResp, err := s.SearchMovies(term, page)
-			if err != nil || searchResp.Response == "False" {
-				continue
-			}
+// Helper function that performs the genre search behind GetMoviesByGenre
+func (s *OMDbService) getMoviesByGenre(ctx context.Context, genre string, limit int) (*GenreResults, error) {
+	// Search terms that are likely to return movies of the specified genre
+	searchTerms := s.getGenreSearchTerms(genre)
 
-			for _, result := range searchResp.Search {
-				if movieSet[result.ImdbID] {
-					continue // Skip duplicates
-				}
-
-				// Get full movie details
-				movieDetails, err := s.GetMovieByTitle(result.Title)
-				if err != nil || movieDetails.Response == "False" {
-					continue
-				}
-
-				// Check if movie contains the desired genre
-				if strings.Contains(strings.ToLower(movieDetails.Genre), strings.ToLower(genre)) {
-					rating, _ := strconv.ParseFloat(movieDetails.ImdbRating, 64)
-					if rating > 0 { // Only include movies with valid ratings
-						movie := models.MovieBrief{
-							Title:      movieDetails.Title,
-							Year:       movieDetails.Year,
-							ImdbRating: movieDetails.ImdbRating,
-							Genre:      movieDetails.Genre,
-							Director:   movieDetails.Director,
-							Plot:       movieDetails.Plot,
-						}
-						allMovies = append(allMovies, movie)
-						movieSet[result.ImdbID] = true
-
-						if len(allMovies) >= limit*2 {
-							break
-						}
-					}
-				}
-			}
-		}
-	}
+	// Get more than needed for better filtering
+	allMovies, failed, err := s.fanOutSearch(ctx, searchTerms, 3, limit*2, "", func(movieDetails *models.OMDbResponse) bool {
+		return strings.Contains(strings.ToLower(movieDetails.Genre), strings.ToLower(genre))
+	})
 
 	// Sort by IMDb rating (descending)
-	sort.Slice(allMovies, func(i, j int) bool {
-		ratingI, _ := strconv.ParseFloat(allMovies[i].ImdbRating, 64)
-		ratingJ, _ := strconv.ParseFloat(allMovies[j].ImdbRating, 64)
-		return ratingI > ratingJ
-	})
+	s.sortMoviesByRating(allMovies)
 
 	// Return top movies up to the limit
 	if len(allMovies) > limit {
 		allMovies = allMovies[:limit]
 	}
 
-	return allMovies, nil
+	// Found nothing because the breaker turned every call away: report the
+	// outage rather than an empty genre
+	if len(allMovies) == 0 && failed > 0 && s.Breaker != nil {
+		if rejectErr := s.Breaker.reject(); rejectErr != nil {
+			return nil, rejectErr
+		}
+	}
+
+	return &GenreResults{Movies: allMovies, FailedRequests: failed}, err
 }
 
-// GetRecommendations provides movie recommendations based on a favorite movie
-func (s *OMDbService) GetRecommendations(favoriteMovie string) (*models.RecommendationsResponse, error) {
+// GetRecommendations provides movie recommendations based on a favorite movie.
+// If ctx is done part way through, the recommendations gathered so far are
+// returned with ctx.Err(). Concurrent calls for the same favorite movie share
+// one computation.
+func (s *OMDbService) GetRecommendations(ctx context.Context, favoriteMovie string) (*models.RecommendationsResponse, error) {
+	if err := s.checkQuotaReserve(); err != nil {
+		return nil, err
+	}
+
+	key := strings.ToLower(strings.TrimSpace(favoriteMovie))
+	recommendations, err := s.recommendations.do(ctx, key, func(ctx context.Context) (*models.RecommendationsResponse, error) {
+		return s.getRecommendations(ctx, favoriteMovie)
+	})
+	if recommendations == nil {
+		return nil, err
+	}
+
+	// Each caller gets its own copy so it can adjust the response freely
+	result := *recommendations
+	result.Recommendations.GenreBased = slices.Clone(recommendations.Recommendations.GenreBased)
+	result.Recommendations.DirectorBased = slices.Clone(recommendations.Recommendations.DirectorBased)
+	result.Recommendations.ActorBased = slices.Clone(recommendations.Recommendations.ActorBased)
+	return &result, err
+}
+
+// Helper function that builds the recommendations behind GetRecommendations
+func (s *OMDbService) getRecommendations(ctx context.Context, favoriteMovie string) (*models.RecommendationsResponse, error) {
 	// Get details of the favorite movie
-	movieDetails, err := s.GetMovieByTitle(favoriteMovie)
-	if err != nil || movieDetails.Response == "False" {
-		return nil, fmt.Errorf("favorite movie not found: %s", favoriteMovie)
+	movieDetails, err := s.GetMovieByTitle(ctx, favoriteMovie)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up favorite movie: %w", err)
 	}
 
 	recommendations := &models.RecommendationsResponse{
@@ -166,109 +349,98 @@ func (s *OMDbService) GetRecommendations(favoriteMovie string) (*models.Recommen
 
 	// Level 1: Genre-based recommendations
 	genres := strings.Split(movieDetails.Genre, ", ")
-	for _, genre := range genres {
-		if len(recommendations.Recommendations.GenreBased) >= 20 {
-			break
-		}
-		genreMovies, err := s.getMoviesExcluding(genre, "genre", movieDetails.Title, 20-len(recommendations.Recommendations.GenreBased))
-		if err == nil {
-			recommendations.Recommendations.GenreBased = append(recommendations.Recommendations.GenreBased, genreMovies...)
-		}
+	if err := s.recommendationPhase(ctx, "genre", genres, movieDetails.ImdbID, &recommendations.Recommendations.GenreBased, &recommendations.FailedRequests); err != nil {
+		s.sortRecommendations(recommendations)
+		return recommendations, err
 	}
 
 	// Level 2: Director-based recommendations
 	directors := strings.Split(movieDetails.Director, ", ")
-	for _, director := range directors {
-		if len(recommendations.Recommendations.DirectorBased) >= 20 {
-			break
-		}
-		directorMovies, err := s.getMoviesExcluding(director, "director", movieDetails.Title, 20-len(recommendations.Recommendations.DirectorBased))
-		if err == nil {
-			recommendations.Recommendations.DirectorBased = append(recommendations.Recommendations.DirectorBased, directorMovies...)
-		}
+	if err := s.recommendationPhase(ctx, "director", directors, movieDetails.ImdbID, &recommendations.Recommendations.DirectorBased, &recommendations.FailedRequests); err != nil {
+		s.sortRecommendations(recommendations)
+		return recommendations, err
 	}
 
 	// Level 3: Actor-based recommendations
 	actors := strings.Split(movieDetails.Actors, ", ")
-	for _, actor := range actors[:min(3, len(actors))] { // Limit to first 3 actors
-		if len(recommendations.Recommendations.ActorBased) >= 20 {
-			break
-		}
-		actorMovies, err := s.getMoviesExcluding(actor, "actor", movieDetails.Title, 20-len(recommendations.Recommendations.ActorBased))
-		if err == nil {
-			recommendations.Recommendations.ActorBased = append(recommendations.Recommendations.ActorBased, actorMovies...)
-		}
+	actors = actors[:min(3, len(actors))] // Limit to first 3 actors
+	if err := s.recommendationPhase(ctx, "actor", actors, movieDetails.ImdbID, &recommendations.Recommendations.ActorBased, &recommendations.FailedRequests); err != nil {
+		s.sortRecommendations(recommendations)
+		return recommendations, err
 	}
 
-	// Sort each category by IMDb rating
-	s.sortMoviesByRating(recommendations.Recommendations.GenreBased)
-	s.sortMoviesByRating(recommendations.Recommendations.DirectorBased)
-	s.sortMoviesByRating(recommendations.Recommendations.ActorBased)
+	s.sortRecommendations(recommendations)
 
 	return recommendations, nil
 }
 
-// Helper function to get movies by criteria while excluding a specific movie
-func (s *OMDbService) getMoviesExcluding(searchTerm, searchType, excludeTitle string, limit int) ([]models.MovieBrief, error) {
-	var movies []models.MovieBrief
-	movieSet := make(map[string]bool)
-
-	// Search for movies
-	for page := 1; page <= 2; page++ {
-		searchResp, err := s.SearchMovies(searchTerm, page)
-		if err != nil || searchResp.Response == "False" {
-			continue
+// Helper function to gather one category of recommendations, searching for
+// each term in turn until 20 movies are found. phase is the search type
+// passed to getMoviesExcluding. Each phase is traced as its own span.
+func (s *OMDbService) recommendationPhase(ctx context.Context, phase string, terms []string, excludeID string, movies *[]models.MovieBrief, failed *int) (err error) {
+	ctx, span := tracer.Start(ctx, "recommendations."+phase, trace.WithAttributes(
+		attribute.StringSlice("recommendations.terms", terms),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("recommendations.movies", len(*movies)))
+		endSpan(span, err)
+	}()
+
+	for _, term := range terms {
+		if len(*movies) >= 20 {
+			break
 		}
-
-		for _, result := range searchResp.Search {
-			if movieSet[result.ImdbID] || strings.EqualFold(result.Title, excludeTitle) {
-				continue
-			}
-
-			movieDetails, err := s.GetMovieByTitle(result.Title)
-			if err != nil || movieDetails.Response == "False" {
-				continue
-			}
-
-			// Check if movie matches the search criteria
-			var matches bool
-			switch searchType {
-			case "genre":
-				matches = strings.Contains(strings.ToLower(movieDetails.Genre), strings.ToLower(searchTerm))
-			case "director":
-				matches = strings.Contains(strings.ToLower(movieDetails.Director), strings.ToLower(searchTerm))
-			case "actor":
-				matches = strings.Contains(strings.ToLower(movieDetails.Actors), strings.ToLower(searchTerm))
-			}
-
-			if matches {
-				rating, _ := strconv.ParseFloat(movieDetails.ImdbRating, 64)
-				if rating > 0 {
-					movie := models.MovieBrief{
-						Title:      movieDetails.Title,
-						Year:       movieDetails.Year,
-						ImdbRating: movieDetails.ImdbRating,
-						Genre:      movieDetails.Genre,
-						Director:   movieDetails.Director,
-						Plot:       movieDetails.Plot,
-					}
-					movies = append(movies, movie)
-					movieSet[result.ImdbID] = true
-
-					if len(movies) >= limit {
-						return movies, nil
-					}
-				}
-			}
+		found, termFailed, err := s.getMoviesExcluding(ctx, term, phase, excludeID, 20-len(*movies))
+		*movies = append(*movies, found...)
+		*failed += termFailed
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return movies, nil
+// Helper function to get movies by criteria while excluding a specific movie.
+// The only error it returns is ctx.Err(), alongside the movies found so far;
+// upstream failures are counted in failed instead.
+func (s *OMDbService) getMoviesExcluding(ctx context.Context, searchTerm, searchType, excludeID string, limit int) (movies []models.MovieBrief, failed int, err error) {
+	ctx, span := tracer.Start(ctx, "getMoviesExcluding", trace.WithAttributes(
+		attribute.String("search.term", searchTerm),
+		attribute.String("search.type", searchType),
+		attribute.Int("search.limit", limit),
+	))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("search.movies", len(movies)),
+			attribute.Int("search.failed_requests", failed),
+		)
+		endSpan(span, err)
+	}()
+
+	return s.fanOutSearch(ctx, []string{searchTerm}, 2, limit, excludeID, func(movieDetails *models.OMDbResponse) bool {
+		// Check if movie matches the search criteria
+		switch searchType {
+		case "genre":
+			return strings.Contains(strings.ToLower(movieDetails.Genre), strings.ToLower(searchTerm))
+		case "director":
+			return strings.Contains(strings.ToLower(movieDetails.Director), strings.ToLower(searchTerm))
+		case "actor":
+			return strings.Contains(strings.ToLower(movieDetails.Actors), strings.ToLower(searchTerm))
+		}
+		return false
+	})
+}
+
+// Helper function to sort each recommendation category by IMDb rating
+func (s *OMDbService) sortRecommendations(recommendations *models.RecommendationsResponse) {
+	s.sortMoviesByRating(recommendations.Recommendations.GenreBased)
+	s.sortMoviesByRating(recommendations.Recommendations.DirectorBased)
+	s.sortMoviesByRating(recommendations.Recommendations.ActorBased)
 }
 
 // Helper function to sort movies by IMDb rating
 func (s *OMDbService) sortMoviesByRating(movies []models.MovieBrief) {
-	sort.Slice(movies, func(i, j int) bool {
+	sort.SliceStable(movies, func(i, j int) bool {
 		ratingI, _ := strconv.ParseFloat(movies[i].ImdbRating, 64)
 		ratingJ, _ := strconv.ParseFloat(movies[j].ImdbRating, 64)
 		return ratingI > ratingJ
@@ -297,31 +469,308 @@ func (s *OMDbService) getGenreSearchTerms(genre string) []string {
 	return terms
 }
 
+// Helper function to refuse expensive fan-outs once the daily quota runs
+// low, leaving what remains for single lookups
+func (s *OMDbService) checkQuotaReserve() error {
+	if s.Quota == nil {
+		return nil
+	}
+	return s.Quota.checkReserve()
+}
+
 // Helper function to make HTTP requests to OMDb API
-func (s *OMDbService) makeRequest(params url.Values) (*models.OMDbResponse, error) {
-	resp, err := s.Client.Get(OMDbBaseURL + "?" + params.Encode())
+func (s *OMDbService) makeRequest(ctx context.Context, kind requestKind, params url.Values) (*models.OMDbResponse, error) {
+	start := time.Now()
+	if s.Store != nil {
+		if record, ok := s.storedRecord(kind, params); ok {
+			if time.Since(record.FetchedAt) >= s.StoreStaleAfter {
+				s.refreshInBackground(ctx, kind, params)
+			}
+			logLookup(ctx, kind, cacheStored, start, nil)
+			return &record.Movie, nil
+		}
+	}
+
+	body, cache, err := s.fetch(ctx, kind, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		logLookup(ctx, kind, cache, start, err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	omdbResp, err := parseOMDbResponse(body)
+	logLookup(ctx, kind, cache, start, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
+	s.storeRecord(kind, params, omdbResp)
+	return omdbResp, nil
+}
+
+// Helper function to decode a title or episode response
+func parseOMDbResponse(body []byte) (*models.OMDbResponse, error) {
 	var omdbResp models.OMDbResponse
 	if err := json.Unmarshal(body, &omdbResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, &UpstreamError{Kind: ErrMalformedResponse, Err: err}
 	}
 
 	if omdbResp.Response == "False" {
-		return nil, fmt.Errorf("OMDb API error: %s", omdbResp.Error)
+		return nil, omdbError(omdbResp.Error, http.StatusOK)
 	}
 
 	return &omdbResp, nil
 }
 
+// Helper function to find the stored record answering a lookup
+func (s *OMDbService) storedRecord(kind requestKind, params url.Values) (StoredRecord, bool) {
+	imdbID := params.Get("i")
+	if imdbID == "" {
+		var ok bool
+		if imdbID, ok = s.Store.Resolve(cacheKey(kind, params)); !ok {
+			return StoredRecord{}, false
+		}
+	}
+	return s.Store.Get(imdbID)
+}
+
+// Helper function to persist a freshly fetched record
+func (s *OMDbService) storeRecord(kind requestKind, params url.Values, omdbResp *models.OMDbResponse) {
+	if s.Store == nil || omdbResp.ImdbID == "" {
+		return
+	}
+	s.Store.Put(cacheKey(kind, params), StoredRecord{Movie: *omdbResp, FetchedAt: time.Now()})
+}
+
+// Helper function to re-fetch a stale stored record without holding up the caller
+func (s *OMDbService) refreshInBackground(ctx context.Context, kind requestKind, params url.Values) {
+	key := cacheKey(kind, params)
+	if _, busy := s.refreshing.LoadOrStore(key, true); busy {
+		return
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	if s.closed {
+		s.refreshing.Delete(key)
+		return
+	}
+	s.refreshes.Add(1)
+
+	go func() {
+		defer s.refreshes.Done()
+		defer s.refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+
+		// Skip the response cache, which may still hold the stale body
+		body, err := s.fetchUpstream(ctx, kind, cacheBypass, params)
+		if err != nil {
+			return
+		}
+		if omdbResp, err := parseOMDbResponse(body); err == nil {
+			s.storeRecord(kind, params, omdbResp)
+		}
+	}()
+}
+
+// Close stops background refreshes from starting and waits for those
+// running to write their records to the store, or for ctx to end. Call it
+// before closing the store.
+func (s *OMDbService) Close(ctx context.Context) error {
+	s.refreshMu.Lock()
+	s.closed = true
+	s.refreshMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.refreshes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background refreshes still running: %w", ctx.Err())
+	}
+}
+
+// Helper function to fetch a response body, answering from the cache when
+// possible. The cache status is returned for logging.
+func (s *OMDbService) fetch(ctx context.Context, kind requestKind, params url.Values) ([]byte, string, error) {
+	if s.Cache == nil {
+		body, err := s.fetchUpstream(ctx, kind, cacheOff, params)
+		return body, cacheOff, err
+	}
+
+	if body, ok := s.Cache.get(kind, params); ok {
+		return body, cacheHit, nil
+	}
+	body, err := s.fetchUpstream(ctx, kind, cacheMiss, params)
+	return body, cacheMiss, err
+}
+
+// Helper function to fetch a response body from OMDb and cache it.
+// Identical requests already in flight share a single upstream call, whose
+// log lines report the cache status of the request that started it.
+func (s *OMDbService) fetchUpstream(ctx context.Context, kind requestKind, cache string, params url.Values) ([]byte, error) {
+	return s.fetches.do(ctx, cacheKey(kind, params), func(ctx context.Context) ([]byte, error) {
+		body, err := s.getWithRetry(ctx, kind, cache, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Cache != nil {
+			s.Cache.put(kind, params, body)
+		}
+		return body, nil
+	})
+}
+
+// Helper function to perform a GET against OMDb and return the raw body of
+// a successful response. Transport failures and non-2xx answers are returned
+// as an *UpstreamError; cancellation of ctx is returned as ctx's error.
+// Errors never carry the API key: the request URL is redacted from them.
+// While the circuit breaker is open the call is not made at all; otherwise
+// it waits its turn on the rate limiter. A key that OMDb rejects, or whose
+// daily quota is used up, is quarantined and the call fails over to the
+// next key in the pool.
+func (s *OMDbService) get(ctx context.Context, kind requestKind, cache string, params url.Values) (body []byte, resultErr error) {
+	called := false // whether any HTTP exchange with OMDb took place
+	if s.Breaker != nil {
+		done, err := s.Breaker.acquire()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { done(called, resultErr) }()
+	}
+	if s.Limiter != nil {
+		if err := s.Limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for range max(s.Keys.Len(), 1) {
+		key, err := s.Keys.pick()
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Quota != nil {
+			if err := s.Quota.reserve(key.id); err != nil {
+				// Our own count ran out; OMDb has not said so, so the key
+				// is only set aside until the reset
+				s.Keys.quarantine(key, ErrQuotaExceeded, s.Quota.resetsAt(time.Now()))
+				lastErr = err
+				continue
+			}
+		}
+
+		start := time.Now()
+		called = true
+		body, err := s.getWithKey(ctx, kind, key, params)
+		logCall(ctx, kind, cache, key, start, err)
+		observeCall(kind, start, err)
+		if err == nil {
+			s.recordSuccess()
+		}
+		if reason := keyRejection(err); reason != nil {
+			var until time.Time
+			if reason == ErrQuotaExceeded && s.Quota != nil {
+				// OMDb's count wins over ours
+				until = s.Quota.exhaust(key.id)
+			}
+			s.Keys.quarantine(key, reason, until)
+			lastErr = err
+			continue
+		}
+		return body, err
+	}
+	return nil, lastErr
+}
+
+// Helper function to perform a single GET against OMDb with the given key.
+// The call is traced as a client span whose context is sent in the
+// traceparent header.
+func (s *OMDbService) getWithKey(ctx context.Context, kind requestKind, key *apiKey, params url.Values) (body []byte, err error) {
+	ctx, span := tracer.Start(ctx, "omdb "+string(kind), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", http.MethodGet),
+		attribute.String("omdb.kind", string(kind)),
+		attribute.String("omdb.key.label", key.id),
+	))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.requestURL(key.value, params), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", redactError(err))
+	}
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, redactError(err))
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// OMDb explains most failures in a JSON body; fall back to the status
+		var status struct {
+			Error string `json:"Error"`
+		}
+		json.Unmarshal(body, &status)
+		upstreamErr := omdbError(status.Error, resp.StatusCode)
+		upstreamErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return nil, upstreamErr
+	}
+
+	return body, nil
+}
+
+// Helper function to tell whether OMDb refused a call because of the key it
+// was made with, returning ErrInvalidAPIKey or ErrQuotaExceeded if so. Only
+// OMDb's own messages count: a bare 429 is rate limiting, not the key.
+func keyRejection(err error) error {
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return nil
+	}
+	switch reason := classifyMessage(upstreamErr.Message); reason {
+	case ErrInvalidAPIKey, ErrQuotaExceeded:
+		return reason
+	}
+	return nil
+}
+
+// Helper function to classify a failure to complete an HTTP exchange
+func transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &UpstreamError{Kind: ErrUpstreamTimeout, Err: err}
+	}
+	return &UpstreamError{Kind: ErrUpstreamUnavailable, Err: err}
+}
+
+// Helper function to build the upstream URL for a set of query parameters
+func (s *OMDbService) requestURL(apiKey string, params url.Values) string {
+	query := maps.Clone(params)
+	query.Set("apikey", apiKey)
+	return s.BaseURL + "?" + query.Encode()
+}
+
 // Helper function for min
 func min(a, b int) int {
 	if a < b {
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-api/models"
+	"go-api/omdbfake"
+)
+
+// fakeOMDb is a fixture-backed OMDb server that counts the calls it gets
+type fakeOMDb struct {
+	*httptest.Server
+	calls atomic.Int64
+}
+
+// newFakeOMDb starts a fake OMDb server with the bundled fixtures, closed
+// when the test ends
+func newFakeOMDb(t *testing.T) *fakeOMDb {
+	t.Helper()
+	fixtures, err := omdbfake.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := omdbfake.NewHandler(fixtures)
+
+	fake := &fakeOMDb{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.calls.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// newTestService returns a service calling fake, with retries off
+func newTestService(fake *fakeOMDb, opts ...Option) *OMDbService {
+	opts = append([]Option{
+		WithBaseURL(fake.URL + "/"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+	}, opts...)
+	return NewOMDbService("test-key", opts...)
+}
+
+func TestBreakerIgnoresLookupsThatMakeNoCall(t *testing.T) {
+	fake := newFakeOMDb(t)
+	breaker := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, OpenDuration: 10 * time.Millisecond})
+	s := newTestService(fake, WithCircuitBreaker(breaker))
+
+	// Trip the breaker and let its open period run out
+	done, err := breaker.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(true, &UpstreamError{Kind: ErrUpstreamUnavailable})
+	time.Sleep(20 * time.Millisecond)
+
+	// With the only key quarantined the lookup fails before calling OMDb
+	key, err := s.Keys.pick()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Keys.quarantine(key, ErrInvalidAPIKey, time.Time{})
+
+	_, err = s.GetMovieByID(context.Background(), "tt0133093")
+	if !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("err = %v, want ErrInvalidAPIKey", err)
+	}
+	if n := fake.calls.Load(); n != 0 {
+		t.Errorf("OMDb got %d calls, want 0", n)
+	}
+	if state := breaker.Status().State; state != "half-open" {
+		t.Errorf("breaker is %s, want half-open", state)
+	}
+
+	// The probe slot was given back
+	if _, err := breaker.acquire(); err != nil {
+		t.Errorf("acquire after the lookup: %v", err)
+	}
+}
+
+// titles returns the titles of movies in order
+func titles(movies []models.MovieBrief) []string {
+	names := make([]string, len(movies))
+	for i, movie := range movies {
+		names[i] = movie.Title
+	}
+	return names
+}
+
+// checkSortedByRating fails the test unless movies are in descending order
+// of IMDb rating
+func checkSortedByRating(t *testing.T, movies []models.MovieBrief) {
+	t.Helper()
+	if !slices.IsSortedFunc(movies, func(a, b models.MovieBrief) int {
+		ratingA, _ := strconv.ParseFloat(a.ImdbRating, 64)
+		ratingB, _ := strconv.ParseFloat(b.ImdbRating, 64)
+		return cmp.Compare(ratingB, ratingA)
+	}) {
+		t.Errorf("movies not sorted by rating: %v", titles(movies))
+	}
+}
+
+func TestGetMoviesByGenre(t *testing.T) {
+	fake := newFakeOMDb(t)
+	s := newTestService(fake)
+
+	results, err := s.GetMoviesByGenre(context.Background(), "action", 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.FailedRequests != 0 {
+		t.Errorf("FailedRequests = %d, want 0", results.FailedRequests)
+	}
+
+	// 18 action movies match the genre's search terms; the three lowest
+	// rated are cut
+	if len(results.Movies) != 15 {
+		t.Fatalf("got %d movies, want 15: %v", len(results.Movies), titles(results.Movies))
+	}
+	checkSortedByRating(t, results.Movies)
+	if got := results.Movies[0].Title; got != "The Adventures of Robin Hood" {
+		t.Errorf("top movie is %q, want The Adventures of Robin Hood", got)
+	}
+	for _, movie := range results.Movies {
+		if !strings.Contains(movie.Genre, "Action") {
+			t.Errorf("%s has genre %q, want Action", movie.Title, movie.Genre)
+		}
+	}
+
+	got := titles(results.Movies)
+	// The Adventures of Ford Fairlane is on the second page of "adventure"
+	if !slices.Contains(got, "The Adventures of Ford Fairlane") {
+		t.Errorf("missing a movie from the second search page: %v", got)
+	}
+	for _, cut := range []string{"Superhero Movie", "The Adventures of Pluto Nash", "The Adventures of Sharkboy and Lavagirl 3-D"} {
+		if slices.Contains(got, cut) {
+			t.Errorf("%s is rated below the top 15 but was returned", cut)
+		}
+	}
+}
+
+func TestGetRecommendations(t *testing.T) {
+	fake := newFakeOMDb(t)
+	s := newTestService(fake)
+
+	recommendations, err := s.GetRecommendations(context.Background(), "the matrix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recommendations.FavoriteMovie != "The Matrix" {
+		t.Errorf("FavoriteMovie = %q, want The Matrix", recommendations.FavoriteMovie)
+	}
+
+	// Searching for "Action" finds these; no titles contain "Sci-Fi", the
+	// directors or the actors
+	byCategory := recommendations.Recommendations
+	want := []string{"Last Action Hero", "Missing in Action", "Action Jackson"}
+	if got := titles(byCategory.GenreBased); !slices.Equal(got, want) {
+		t.Errorf("genre based = %v, want %v", got, want)
+	}
+	if len(byCategory.DirectorBased) != 0 || len(byCategory.ActorBased) != 0 {
+		t.Errorf("director based = %v, actor based = %v, want none", titles(byCategory.DirectorBased), titles(byCategory.ActorBased))
+	}
+}
+
+func TestGetRecommendationsByDirectorAndActor(t *testing.T) {
+	fake := newFakeOMDb(t)
+	s := newTestService(fake)
+
+	recommendations, err := s.GetRecommendations(context.Background(), "Tyler Perry's Why Did I Get Married?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The favorite matches its own director and actor searches but is left out
+	byCategory := recommendations.Recommendations
+	for _, category := range []struct {
+		name string
+		got  []models.MovieBrief
+		want []string
+	}{
+		{"genre", byCategory.GenreBased, []string{"True Romance"}},
+		{"director", byCategory.DirectorBased, []string{"Tyler Perry's Madea's Family Reunion"}},
+		{"actor", byCategory.ActorBased, []string{"Tyler Perry's Madea's Family Reunion"}},
+	} {
+		if got := titles(category.got); !slices.Equal(got, category.want) {
+			t.Errorf("%s based = %v, want %v", category.name, got, category.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+
+	"go-api/models"
+)
+
+// MovieProvider is the set of movie lookups the HTTP handlers depend on.
+// OMDbService is the production implementation; FakeProvider serves the
+// same data from memory. Every method honors cancellation of ctx; the fan-out
+// methods return whatever they gathered before ctx was done alongside ctx.Err().
+type MovieProvider interface {
+	GetMovieByTitle(ctx context.Context, title string) (*models.OMDbResponse, error)
+	FindTitle(ctx context.Context, query TitleQuery) (*models.OMDbResponse, error)
+	TitleMatches(ctx context.Context, query TitleQuery) ([]models.SearchResult, error)
+	GetMovieByID(ctx context.Context, imdbID string) (*models.OMDbResponse, error)
+	GetEpisodeDetails(ctx context.Context, seriesTitle string, season, episode int) (*models.OMDbResponse, error)
+	SearchMovies(ctx context.Context, query string, page int) (*models.SearchResponse, error)
+	Search(ctx context.Context, query SearchQuery) (*SearchResults, error)
+	GetMoviesByGenre(ctx context.Context, genre string, limit int) (*GenreResults, error)
+	GetRecommendations(ctx context.Context, favoriteMovie string) (*models.RecommendationsResponse, error)
+}
+
+// TitleQuery identifies a title lookup. Year and Type (movie, series or
+// episode) are optional and narrow the lookup when set.
+type TitleQuery struct {
+	Title string
+	Year  string
+	Type  string
+}
+
+// HealthReporter is implemented by providers that can describe the health
+// of the upstream they depend on
+type HealthReporter interface {
+	Health() UpstreamHealth
+}
+
+// ReadinessReporter is implemented by providers that can check whether
+// they are ready to serve traffic
+type ReadinessReporter interface {
+	Readiness(ctx context.Context) []Check
+}
+
+// UpstreamHealth describes the upstream behind a provider
+type UpstreamHealth struct {
+	// Breaker is nil when no circuit breaker is configured
+	Breaker *BreakerStatus `json:"circuit_breaker,omitempty"`
+}
+
+// Degraded reports whether the upstream is currently being avoided
+func (h UpstreamHealth) Degraded() bool {
+	return h.Breaker != nil && h.Breaker.State != BreakerClosed.String()
+}
+
+// GenreResults are the best rated movies found for a genre. FailedRequests
+// counts the upstream lookups that failed even after retrying, which may
+// have left Movies shorter than it should be.
+type GenreResults struct {
+	Movies         []models.MovieBrief
+	FailedRequests int
+}
+
+var (
+	_ MovieProvider = (*OMDbService)(nil)
+	_ MovieProvider = (*FakeProvider)(nil)
+
+	_ HealthReporter    = (*OMDbService)(nil)
+	_ ReadinessReporter = (*OMDbService)(nil)
+)
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// QuotaConfig describes the daily request allowance of the OMDb API keys
+type QuotaConfig struct {
+	// KeyIDs are the labels of the keys being tracked, as returned by
+	// KeyPool.IDs. Labels follow the order of the configured keys, so a
+	// saved count stays with a key as long as that order does.
+	KeyIDs []string
+
+	// DailyLimit is the number of upstream calls each key may make per day
+	DailyLimit int
+
+	// Reserve is the part of the combined allowance kept for cheap lookups:
+	// once fewer calls than this remain, expensive fan-out endpoints are
+	// refused
+	Reserve int
+
+	// Location is the time zone whose midnight starts a new quota day;
+	// nil means UTC
+	Location *time.Location
+}
+
+// QuotaStatus is a snapshot of a QuotaTracker. Limit, Used and Remaining
+// are summed over all keys.
+type QuotaStatus struct {
+	Day       string     `json:"day"`
+	Limit     int        `json:"limit"`
+	Used      int        `json:"used"`
+	Remaining int        `json:"remaining"`
+	Reserve   int        `json:"reserve"`
+	ResetsAt  time.Time  `json:"resets_at"`
+	Keys      []KeyQuota `json:"keys"`
+}
+
+// KeyQuota is the usage of one key for the day
+type KeyQuota struct {
+	Key       string `json:"key"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+
+	// Exhausted is set once OMDb itself reports the limit reached, which
+	// can happen before our own count gets there
+	Exhausted bool `json:"exhausted"`
+}
+
+// QuotaTracker counts upstream calls against the daily allowance of each
+// key and refuses calls on a key once it is used up. The counts survive
+// restarts when the tracker is backed by a file; it is written periodically
+// and on Close.
+type QuotaTracker struct {
+	config QuotaConfig
+
+	mu    sync.Mutex
+	state quotaState
+
+	flusher *fileFlusher
+}
+
+type quotaState struct {
+	Day  string               `json:"day"`
+	Keys map[string]*keyUsage `json:"keys"`
+}
+
+type keyUsage struct {
+	Used      int  `json:"used"`
+	Exhausted bool `json:"exhausted"`
+}
+
+// OpenQuotaTracker loads the counts saved at path for today, if any. An
+// empty path keeps the counts in memory only. A positive flushInterval
+// starts a background flusher.
+func OpenQuotaTracker(path string, config QuotaConfig, flushInterval time.Duration) (*QuotaTracker, error) {
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+	q := &QuotaTracker{config: config}
+	q.flusher = newFileFlusher(path, "quota counter", q.snapshot)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read quota counter: %w", err)
+		default:
+			if err := json.Unmarshal(data, &q.state); err != nil {
+				return nil, fmt.Errorf("failed to parse quota counter: %w", err)
+			}
+		}
+	}
+	q.rollover(time.Now())
+
+	q.flusher.start(flushInterval)
+	return q, nil
+}
+
+// WithQuota counts upstream calls against a daily allowance
+func WithQuota(quota *QuotaTracker) Option {
+	return func(s *OMDbService) {
+		s.Quota = quota
+	}
+}
+
+// reserve counts one upstream call on a key, or refuses it with an error
+// wrapping ErrQuotaExceeded if the key's allowance is used up
+func (q *QuotaTracker) reserve(keyID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.rollover(now)
+	usage := q.usage(keyID)
+	if usage.Exhausted || usage.Used >= q.config.DailyLimit {
+		return &UpstreamError{Kind: ErrQuotaExceeded, RetryAfter: q.resetsAt(now).Sub(now)}
+	}
+	usage.Used++
+	q.flusher.markDirty()
+	return nil
+}
+
+// checkReserve refuses expensive work once the remaining allowance of all
+// keys together has dropped into the reserve
+func (q *QuotaTracker) checkReserve() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.rollover(now)
+	remaining := 0
+	for _, keyID := range q.config.KeyIDs {
+		remaining += q.remaining(q.usage(keyID))
+	}
+	if remaining < max(q.config.Reserve, 1) {
+		return &UpstreamError{Kind: ErrQuotaExceeded, RetryAfter: q.resetsAt(now).Sub(now)}
+	}
+	return nil
+}
+
+// exhaust records that OMDb reported the limit of a key reached for today
+// and returns when the quota resets
+func (q *QuotaTracker) exhaust(keyID string) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.rollover(now)
+	if usage := q.usage(keyID); !usage.Exhausted {
+		usage.Exhausted = true
+		q.flusher.markDirty()
+	}
+	return q.resetsAt(now)
+}
+
+// Status returns a snapshot of today's usage
+func (q *QuotaTracker) Status() QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.rollover(now)
+	status := QuotaStatus{
+		Day:      q.state.Day,
+		Reserve:  q.config.Reserve,
+		ResetsAt: q.resetsAt(now),
+		Keys:     make([]KeyQuota, len(q.config.KeyIDs)),
+	}
+	for i, keyID := range q.config.KeyIDs {
+		usage := q.usage(keyID)
+		status.Keys[i] = KeyQuota{
+			Key:       keyID,
+			Used:      usage.Used,
+			Remaining: q.remaining(usage),
+			Exhausted: usage.Exhausted,
+		}
+		status.Limit += q.config.DailyLimit
+		status.Used += usage.Used
+		status.Remaining += status.Keys[i].Remaining
+	}
+	return status
+}
+
+// usage returns the day's counters of a key, creating them if needed.
+// q.mu must be held.
+func (q *QuotaTracker) usage(keyID string) *keyUsage {
+	usage, ok := q.state.Keys[keyID]
+	if !ok {
+		usage = &keyUsage{}
+		q.state.Keys[keyID] = usage
+	}
+	return usage
+}
+
+func (q *QuotaTracker) remaining(usage *keyUsage) int {
+	if usage.Exhausted {
+		return 0
+	}
+	return max(q.config.DailyLimit-usage.Used, 0)
+}
+
+// rollover starts a fresh count when the quota day has changed. q.mu must
+// be held, or q not yet shared.
+func (q *QuotaTracker) rollover(now time.Time) {
+	day := now.In(q.config.Location).Format(time.DateOnly)
+	if q.state.Day != day || q.state.Keys == nil {
+		q.state = quotaState{Day: day, Keys: make(map[string]*keyUsage)}
+		q.flusher.markDirty()
+	}
+}
+
+// resetsAt returns the next midnight in the quota time zone
+func (q *QuotaTracker) resetsAt(now time.Time) time.Time {
+	local := now.In(q.config.Location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, q.config.Location)
+}
+
+// Flush writes the count to the file, if there is one
+func (q *QuotaTracker) Flush() error {
+	return q.flusher.flush()
+}
+
+// Close stops the background flusher and flushes
+func (q *QuotaTracker) Close() error {
+	return q.flusher.close()
+}
+
+func (q *QuotaTracker) snapshot() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return json.Marshal(q.state)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaDayRolloverInLocation(t *testing.T) {
+	tokyo := time.FixedZone("UTC+9", 9*60*60)
+	q, err := OpenQuotaTracker("", QuotaConfig{KeyIDs: []string{"key-1"}, DailyLimit: 100, Location: tokyo}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 23:00 in Tokyo on 1 March
+	evening := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	q.rollover(evening)
+	q.usage("key-1").Used = 5
+	if q.state.Day != "2026-03-01" {
+		t.Fatalf("day = %s, want 2026-03-01", q.state.Day)
+	}
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, tokyo); !q.resetsAt(evening).Equal(want) {
+		t.Errorf("resets at %v, want %v", q.resetsAt(evening), want)
+	}
+
+	q.rollover(evening.Add(59 * time.Minute))
+	if used := q.usage("key-1").Used; used != 5 {
+		t.Errorf("used = %d before midnight in Tokyo, want 5", used)
+	}
+
+	// Past midnight in Tokyo while still 1 March in UTC
+	q.rollover(evening.Add(61 * time.Minute))
+	if q.state.Day != "2026-03-02" {
+		t.Errorf("day = %s after midnight in Tokyo, want 2026-03-02", q.state.Day)
+	}
+	if used := q.usage("key-1").Used; used != 0 {
+		t.Errorf("used = %d after midnight in Tokyo, want 0", used)
+	}
+}
+
+func TestQuotaRefusesKeyOnceUsedUp(t *testing.T) {
+	q, err := OpenQuotaTracker("", QuotaConfig{KeyIDs: []string{"key-1", "key-2"}, DailyLimit: 2}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := q.reserve("key-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = q.reserve("key-1")
+	var upstreamErr *UpstreamError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &upstreamErr) || upstreamErr.RetryAfter <= 0 {
+		t.Fatalf("third call on key-1: %v, want ErrQuotaExceeded with a RetryAfter", err)
+	}
+	if err := q.reserve("key-2"); err != nil {
+		t.Errorf("key-2: %v", err)
+	}
+
+	q.exhaust("key-2")
+	if err := q.reserve("key-2"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("key-2 after OMDb reported it exhausted: %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestQuotaReloadsSavedCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	config := QuotaConfig{KeyIDs: []string{"key-1", "key-2"}, DailyLimit: 10}
+
+	q, err := OpenQuotaTracker(path, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := q.reserve("key-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.exhaust("key-2")
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = OpenQuotaTracker(path, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := q.Status()
+	if status.Used != 3 || status.Remaining != 7 {
+		t.Errorf("reloaded used %d, remaining %d, want 3 and 7", status.Used, status.Remaining)
+	}
+	if !status.Keys[1].Exhausted {
+		t.Errorf("reloaded key-2 is not exhausted")
+	}
+}
+
+func TestQuotaDiscardsSavedCountOfAnotherDay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	saved := `{"day":"2000-01-01","keys":{"key-1":{"used":9,"exhausted":true}}}`
+	if err := os.WriteFile(path, []byte(saved), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := OpenQuotaTracker(path, QuotaConfig{KeyIDs: []string{"key-1"}, DailyLimit: 10}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := q.Status(); status.Used != 0 || status.Keys[0].Exhausted {
+		t.Errorf("status = %+v, want a fresh day", status)
+	}
+}
+
+func TestQuotaReserveRefusesFanOuts(t *testing.T) {
+	fake := newFakeOMDb(t)
+	s := newTestService(fake)
+	quota, err := OpenQuotaTracker("", QuotaConfig{KeyIDs: s.Keys.IDs(), DailyLimit: 10, Reserve: 5}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Quota = quota
+
+	// Leave 4 calls, fewer than the reserve of 5
+	for range 6 {
+		if err := quota.reserve(s.Keys.IDs()[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	if _, err := s.GetMoviesByGenre(ctx, "action", 15); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("genre: %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := s.GetRecommendations(ctx, "The Matrix"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("recommendations: %v, want ErrQuotaExceeded", err)
+	}
+	if n := fake.calls.Load(); n != 0 {
+		t.Errorf("OMDb got %d calls for refused fan-outs, want 0", n)
+	}
+
+	// Single lookups may still use the reserve
+	if _, err := s.GetMovieByID(ctx, "tt0133093"); err != nil {
+		t.Errorf("lookup within the reserve: %v", err)
+	}
+	if used := quota.Status().Used; used != 7 {
+		t.Errorf("used = %d, want 7", used)
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultProbeInterval is how often readiness checks may call OMDb when no
+// other call has succeeded recently. Each probe spends one call of quota.
+const defaultProbeInterval = 15 * time.Minute
+
+// failedProbeRetry is how soon a failed probe may be repeated. It is short
+// because an instance that is not ready gets no traffic to show otherwise.
+const failedProbeRetry = 30 * time.Second
+
+// probeTimeout bounds a readiness probe of OMDb
+const probeTimeout = 5 * time.Second
+
+// probeIMDbID is the title looked up by readiness probes
+const probeIMDbID = "tt0133093"
+
+// CheckStatus is the outcome of a readiness check
+type CheckStatus string
+
+const (
+	CheckOK       CheckStatus = "ok"
+	CheckDegraded CheckStatus = "degraded" // working, with reduced service
+	CheckFailed   CheckStatus = "fail"     // not able to serve
+)
+
+// Check is the result of one readiness check
+type Check struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// readiness remembers what the readiness checks have learnt about OMDb
+type readiness struct {
+	// lastSuccess is when OMDb last answered a call, in Unix nanoseconds
+	lastSuccess atomic.Int64
+
+	mu        sync.Mutex
+	lastProbe time.Time
+	probeErr  error
+}
+
+// WithReadinessProbe sets how often readiness checks may call OMDb to see
+// whether it is reachable. Probes are skipped while other calls succeed.
+func WithReadinessProbe(interval time.Duration) Option {
+	return func(s *OMDbService) {
+		s.ProbeInterval = interval
+	}
+}
+
+// Readiness checks the API keys, OMDb, the circuit breaker and the files
+// the service writes to. A failed check means the service cannot answer
+// lookups it has not seen before; degraded ones mean it answers some.
+func (s *OMDbService) Readiness(ctx context.Context) []Check {
+	// Probe first, so that keys it finds rejected show in the key check
+	upstream := s.checkUpstream(ctx)
+	checks := []Check{s.checkKeys(), upstream}
+	if s.Breaker != nil {
+		checks = append(checks, s.checkBreaker())
+	}
+	if store, ok := s.Store.(interface{ CheckWritable() error }); ok {
+		checks = append(checks, writableCheck("store", store.CheckWritable()))
+	}
+	if s.Quota != nil && s.Quota.flusher.path != "" {
+		checks = append(checks, writableCheck("quota_store", s.Quota.CheckWritable()))
+	}
+	return checks
+}
+
+func (s *OMDbService) checkKeys() Check {
+	check := Check{Name: "api_keys", Status: CheckOK}
+	usable, invalid, total := s.Keys.health()
+	switch {
+	case invalid == total:
+		check.Status, check.Detail = CheckFailed, "OMDb rejected every API key"
+	case usable == 0:
+		check.Status, check.Detail = CheckDegraded, "every API key is out of quota until it resets"
+	case usable < total:
+		check.Status, check.Detail = CheckDegraded, fmt.Sprintf("%d of %d API keys usable", usable, total)
+	}
+	return check
+}
+
+// checkUpstream passes if OMDb answered a call recently, probing it if
+// not. When it cannot be reached, cached and stored data keep the service
+// partly useful, so the check is only degraded if there is any.
+func (s *OMDbService) checkUpstream(ctx context.Context) Check {
+	check := Check{Name: "omdb", Status: CheckOK}
+	if last := s.readiness.lastSuccess.Load(); last != 0 && time.Since(time.Unix(0, last)) < s.ProbeInterval {
+		check.Detail = "answered " + time.Since(time.Unix(0, last)).Round(time.Second).String() + " ago"
+		return check
+	}
+
+	err := s.probe(ctx)
+	if err == nil {
+		check.Detail = "answered a probe"
+		return check
+	}
+
+	check.Status, check.Detail = CheckFailed, "unreachable: "+outcome(err)
+	if s.warm() {
+		check.Status, check.Detail = CheckDegraded, check.Detail+"; serving cached data"
+	}
+	return check
+}
+
+// probe calls OMDb unless a recent probe can answer instead: a successful
+// one within the probe interval or a failed one within failedProbeRetry
+func (s *OMDbService) probe(ctx context.Context) error {
+	r := &s.readiness
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reuseFor := s.ProbeInterval
+	if r.probeErr != nil {
+		reuseFor = failedProbeRetry
+	}
+	if !r.lastProbe.IsZero() && time.Since(r.lastProbe) < reuseFor {
+		return r.probeErr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	_, err := s.get(ctx, kindDetails, cacheBypass, url.Values{"i": {probeIMDbID}})
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		// No call was completed, so there is nothing to remember
+		return err
+	}
+	r.lastProbe, r.probeErr = time.Now(), err
+	return err
+}
+
+// recordSuccess notes that OMDb answered a call
+func (s *OMDbService) recordSuccess() {
+	s.readiness.lastSuccess.Store(time.Now().UnixNano())
+}
+
+// warm reports whether the cache or store holds anything to serve
+func (s *OMDbService) warm() bool {
+	if s.Cache != nil && s.Cache.Stats().Entries > 0 {
+		return true
+	}
+	if store, ok := s.Store.(interface{ Len() int }); ok && store.Len() > 0 {
+		return true
+	}
+	return false
+}
+
+func (s *OMDbService) checkBreaker() Check {
+	status := s.Breaker.Status()
+	check := Check{Name: "circuit_breaker", Status: CheckOK, Detail: status.State}
+	if status.State != BreakerClosed.String() {
+		check.Status = CheckDegraded
+		if !status.RetryAt.IsZero() {
+			check.Detail += ", retrying at " + status.RetryAt.Format(time.RFC3339)
+		}
+	}
+	return check
+}
+
+func writableCheck(name string, err error) Check {
+	if err != nil {
+		return Check{Name: name, Status: CheckFailed, Detail: err.Error()}
+	}
+	return Check{Name: name, Status: CheckOK}
+}
+
+// health counts the keys that may be used now and those OMDb rejected
+func (p *KeyPool) health() (usable, invalid, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range p.keys {
+		switch {
+		case !now.Before(key.quarantinedUntil):
+			usable++
+		case key.quarantineReason == ErrInvalidAPIKey:
+			invalid++
+		}
+	}
+	return usable, invalid, len(p.keys)
+}
+
+// CheckWritable reports whether the store's file can be written
+func (s *FileStore) CheckWritable() error {
+	return checkWritable(s.flusher.path)
+}
+
+// CheckWritable reports whether the quota counter's file can be written
+func (q *QuotaTracker) CheckWritable() error {
+	return checkWritable(q.flusher.path)
+}
+
+// checkWritable creates and removes a file next to path, as writeFileAtomic
+// does when it replaces the file
+func checkWritable(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".writable-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+)
+
+// redactedValue replaces the API key wherever an upstream URL is reported
+const redactedValue = "REDACTED"
+
+// apiKeyParam matches the apikey query parameter and its value in a URL,
+// whether or not the URL as a whole parses
+var apiKeyParam = regexp.MustCompile(`(?i)((?:^|[?&;])apikey=)[^&;#\s"]*`)
+
+// RedactURL returns rawURL with the value of any apikey query parameter
+// replaced, so that upstream URLs are safe to log or report
+func RedactURL(rawURL string) string {
+	return apiKeyParam.ReplaceAllString(rawURL, "${1}"+redactedValue)
+}
+
+// redactError scrubs the API key from every *url.Error in err's chain. The
+// net/http client reports failures as *url.Error carrying the full request
+// URL, so without this the key would reach any log line or response that
+// prints the error.
+func redactError(err error) error {
+	for target := err; target != nil; {
+		var urlErr *url.Error
+		if !errors.As(target, &urlErr) {
+			break
+		}
+		urlErr.URL = RedactURL(urlErr.URL)
+		target = urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-api/handlers"
+	"go-api/middleware"
+	"go-api/omdbfake"
+	"go-api/services"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// secretKey is the API key no error, log line, response or span may carry
+const secretKey = "s3cr3tk3y"
+
+// TestErrorsNeverCarryAPIKey drives every way an upstream call can fail and
+// checks that the key reaches neither the returned error, the logs, the
+// problem+json response nor the recorded spans
+func TestErrorsNeverCarryAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	previousLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previousLogger) })
+
+	spans := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previousProvider) })
+
+	fixtures, err := omdbfake.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unreachable: a server that has already shut down
+	closed := omdbfake.NewServer(fixtures)
+	closed.Close()
+
+	// Slow: answers after the client has given up
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(slow.Close)
+
+	// Rejecting: answers 401 to any key but its own
+	rejecting := httptest.NewServer(&omdbfake.Handler{APIKey: "another-key"})
+	t.Cleanup(rejecting.Close)
+
+	// Failing: answers 503
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+
+	tests := []struct {
+		name          string
+		baseURL       string
+		clientTimeout time.Duration
+		wantStatus    int
+	}{
+		{"transport error", closed.URL + "/", 0, http.StatusBadGateway},
+		{"malformed base URL", "http://[::1/", 0, http.StatusInternalServerError},
+		{"client timeout", slow.URL + "/", 50 * time.Millisecond, http.StatusGatewayTimeout},
+		{"rejected key", rejecting.URL + "/", 0, http.StatusBadGateway},
+		{"server error", failing.URL + "/", 0, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			spans.Reset()
+
+			client := &http.Client{Timeout: tt.clientTimeout}
+			service := services.NewOMDbService(secretKey,
+				services.WithBaseURL(tt.baseURL),
+				services.WithHTTPClient(client),
+				services.WithRetryPolicy(services.RetryPolicy{MaxAttempts: 1}),
+			)
+
+			_, err := service.GetMovieByID(context.Background(), "tt0133093")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assertNoKey(t, "error", err.Error())
+
+			router := gin.New()
+			router.Use(middleware.RequestID())
+			router.GET("/api/movie/:imdbID", handlers.NewMovieHandler(service).GetMovieByID)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/movie/tt0133093", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/problem+json") {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			assertNoKey(t, "problem body", recorder.Body.String())
+
+			if !strings.Contains(logs.String(), `"msg":"request failed"`) {
+				t.Errorf("no request failed log line in:\n%s", logs.String())
+			}
+			assertNoKey(t, "logs", logs.String())
+
+			ended := spans.Ended()
+			if len(ended) == 0 {
+				t.Fatal("no spans recorded")
+			}
+			for _, span := range ended {
+				assertNoKey(t, "span "+span.Name(), fmt.Sprint(span.Status(), span.Attributes(), span.Events()))
+			}
+		})
+	}
+}
+
+func assertNoKey(t *testing.T, where, text string) {
+	t.Helper()
+	if strings.Contains(text, secretKey) {
+		t.Errorf("%s carries the API key: %s", where, text)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for the retry policy of a new OMDbService
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 200 * time.Millisecond
+	defaultRetryMaxDelay  = 2 * time.Second
+)
+
+// RetryPolicy controls how upstream calls that fail transiently (network
+// errors, timeouts and 5xx answers) are retried. The delay before retry n is
+// drawn uniformly from [0, min(MaxDelay, BaseDelay*2^(n-1))), so concurrent
+// callers spread out instead of retrying in lockstep. A Retry-After sent by
+// OMDb is honored as the minimum delay, unless it exceeds MaxDelay in which
+// case the call fails without waiting.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first;
+	// one or less disables retries
+	MaxAttempts int
+
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// WithRetryPolicy replaces the default retry policy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *OMDbService) {
+		s.Retry = policy
+	}
+}
+
+// backoff returns the jittered delay before the given retry, counting from 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.MaxDelay
+	// Compare before shifting so that a shift overflowing int64 cannot wrap
+	// round to a small delay
+	if shift := retry - 1; shift < 63 && p.BaseDelay > 0 && p.BaseDelay <= ceiling>>shift {
+		ceiling = p.BaseDelay << shift
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// Helper function to call get, retrying transient failures according to
+// s.Retry. Waiting between attempts stops as soon as ctx is done, returning
+// the last upstream error. ctx is the context of a coalesced fetch, which
+// carries no deadline: it is cancelled once every request waiting on the
+// fetch has given up, so no single request's deadline cuts short the
+// retries another is still waiting for.
+func (s *OMDbService) getWithRetry(ctx context.Context, kind requestKind, cache string, params url.Values) ([]byte, error) {
+	for retry := 1; ; retry++ {
+		body, err := s.get(ctx, kind, cache, params)
+		if err == nil || retry >= s.Retry.MaxAttempts || !retryable(err) {
+			return body, err
+		}
+
+		delay := s.Retry.backoff(retry)
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > delay {
+			if upstreamErr.RetryAfter > s.Retry.MaxDelay {
+				return nil, err
+			}
+			delay = upstreamErr.RetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether a failed upstream call may succeed if repeated.
+// OMDb's own answers, such as "not found" or an exhausted daily quota, are
+// final. A bare 429 comes from rate limiting in front of OMDb and is retried.
+func retryable(err error) bool {
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return false
+	}
+	switch {
+	case errors.Is(upstreamErr.Kind, ErrUpstreamUnavailable), errors.Is(upstreamErr.Kind, ErrUpstreamTimeout):
+		return true
+	case upstreamErr.StatusCode == http.StatusTooManyRequests:
+		return upstreamErr.Message == ""
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date, returning zero when it is absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
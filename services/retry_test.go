@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries quickly enough for tests
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// newScriptedService returns a service whose OMDb answers call n (counting
+// from 1) with respond, and a counter of the calls made
+func newScriptedService(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, n int64), opts ...Option) (*OMDbService, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, calls.Add(1))
+	}))
+	t.Cleanup(server.Close)
+
+	opts = append([]Option{WithBaseURL(server.URL + "/"), WithRetryPolicy(fastRetries)}, opts...)
+	return NewOMDbService("test-key", opts...), &calls
+}
+
+const matrixBody = `{"Title":"The Matrix","imdbID":"tt0133093","Response":"True"}`
+
+func TestRetryOn5xx(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(matrixBody))
+	})
+
+	movie, err := s.GetMovieByID(context.Background(), "tt0133093")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movie.Title != "The Matrix" {
+		t.Errorf("got %q, want The Matrix", movie.Title)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("OMDb got %d calls, want 3", n)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := s.GetMovieByID(context.Background(), "tt0133093")
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("err = %v, want ErrUpstreamUnavailable", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("OMDb got %d calls, want 3", n)
+	}
+}
+
+func TestRetryOnTimeout(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if n == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(matrixBody))
+	}, WithHTTPClient(&http.Client{Timeout: 20 * time.Millisecond}))
+
+	if _, err := s.GetMovieByID(context.Background(), "tt0133093"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("OMDb got %d calls, want 2", n)
+	}
+}
+
+func TestNoRetryOnOMDbAnswers(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{"not found", http.StatusNotFound, `{"Response":"False","Error":"Movie not found!"}`, ErrNotFound},
+		{"daily limit", http.StatusTooManyRequests, `{"Response":"False","Error":"Request limit reached!"}`, ErrQuotaExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := s.GetMovieByID(context.Background(), "tt0133093")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if n := calls.Load(); n != 1 {
+				t.Errorf("OMDb got %d calls, want 1", n)
+			}
+		})
+	}
+}
+
+func TestRetryOnBare429(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		if n == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(matrixBody))
+	})
+
+	if _, err := s.GetMovieByID(context.Background(), "tt0133093"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("OMDb got %d calls, want 2", n)
+	}
+}
+
+func TestRetryAfterAboveMaxDelayFailsImmediately(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	start := time.Now()
+	_, err := s.GetMovieByID(context.Background(), "tt0133093")
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.RetryAfter != 30*time.Second {
+		t.Fatalf("err = %v, want an upstream error asking to retry after 30s", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("OMDb got %d calls, want 1", n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, want no wait", elapsed)
+	}
+}
+
+func TestRetryCancelledWhileWaiting(t *testing.T) {
+	s, calls := newScriptedService(t, func(w http.ResponseWriter, r *http.Request, n int64) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Hour}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := s.getWithRetry(ctx, kindDetails, cacheBypass, url.Values{"i": {"tt0133093"}})
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("err = %v, want the last upstream error", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the upstream error rather than the context's", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("OMDb got %d calls, want 1", n)
+	}
+}
+
+func TestBackoffCeiling(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		retry   int
+		ceiling time.Duration
+	}{
+		{"first retry", RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}, 1, 100 * time.Millisecond},
+		{"doubling", RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}, 3, 400 * time.Millisecond},
+		{"capped", RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}, 10, 2 * time.Second},
+		{"shift past int64", RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}, 100, 2 * time.Second},
+		// 2^40+1 shifted by 24 wraps round to 2^24ns
+		{"overflow wrapping small", RetryPolicy{BaseDelay: 1<<40 + 1, MaxDelay: 2 * time.Second}, 25, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var longest time.Duration
+			for range 200 {
+				d := tt.policy.backoff(tt.retry)
+				if d < 0 || d >= tt.ceiling {
+					t.Fatalf("backoff(%d) = %v, want within [0, %v)", tt.retry, d, tt.ceiling)
+				}
+				longest = max(longest, d)
+			}
+			// The jitter should reach well into the range
+			if longest < tt.ceiling/2 {
+				t.Errorf("longest of 200 backoffs is %v, want some above %v", longest, tt.ceiling/2)
+			}
+		})
+	}
+}
+
+func TestBackoffWithoutDelays(t *testing.T) {
+	if d := (RetryPolicy{}).backoff(1); d != 0 {
+		t.Errorf("backoff with no delays = %v, want 0", d)
+	}
+}
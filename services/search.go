@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go-api/models"
+)
+
+const (
+	// omdbPageSize is the fixed number of results OMDb returns per search page
+	omdbPageSize = 10
+
+	// omdbMaxPage is the last search page OMDb will serve
+	omdbMaxPage = 100
+)
+
+// SearchQuery describes a search. Type and Year are optional filters. Page
+// is 1-based and PageSize may exceed OMDb's fixed page size, in which case
+// the results of several upstream pages are combined.
+type SearchQuery struct {
+	Query    string
+	Type     string
+	Year     string
+	Page     int
+	PageSize int
+}
+
+// SearchResults is one page of a search
+type SearchResults struct {
+	Results []models.SearchResult
+
+	// TotalResults is the number of hits OMDb reports for the whole search
+	TotalResults int
+}
+
+// Search returns one page of search results. A search without hits yields
+// empty results rather than an error.
+func (s *OMDbService) Search(ctx context.Context, query SearchQuery) (*SearchResults, error) {
+	first, last := upstreamPages(query.Page, query.PageSize)
+	if first > last {
+		return &SearchResults{}, nil
+	}
+
+	responses := make([]*models.SearchResponse, last-first+1)
+	errs := make([]error, len(responses))
+	s.forEach(ctx, len(responses), func(i int) {
+		responses[i], errs[i] = s.search(ctx, query.Query, query.Type, query.Year, first+i)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Concatenate upstream pages in order, stopping at the end of the results
+	var hits []models.SearchResult
+	total := -1
+	for i, searchResp := range responses {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if n, err := strconv.Atoi(searchResp.TotalResults); err == nil {
+			total = n
+		}
+		if searchResp.Response == "False" {
+			if isNotFoundMessage(searchResp.Error) {
+				break
+			}
+			return nil, omdbError(searchResp.Error, http.StatusOK)
+		}
+		hits = append(hits, searchResp.Search...)
+	}
+
+	// A page past the end answers "not found" without a count, so ask the
+	// first page how many results there are
+	if total < 0 && first > 1 {
+		searchResp, err := s.search(ctx, query.Query, query.Type, query.Year, 1)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := strconv.Atoi(searchResp.TotalResults); err == nil {
+			total = n
+		}
+	}
+	total = max(total, 0)
+
+	// Trim to the requested window within the upstream pages fetched
+	offset := (query.Page-1)*query.PageSize - (first-1)*omdbPageSize
+	if offset >= len(hits) {
+		return &SearchResults{TotalResults: total}, nil
+	}
+	end := min(offset+query.PageSize, len(hits))
+
+	return &SearchResults{Results: hits[offset:end], TotalResults: total}, nil
+}
+
+// upstreamPages returns the range of OMDb pages covering a client page
+func upstreamPages(page, pageSize int) (first, last int) {
+	start := (page - 1) * pageSize
+	end := page*pageSize - 1
+	first = start/omdbPageSize + 1
+	last = min(end/omdbPageSize+1, omdbMaxPage)
+	return first, last
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go-api/omdbfake"
+)
+
+// fixtureTitles returns, in fixture order, the titles the fake finds when
+// searching for term
+func fixtureTitles(t *testing.T, term string) []string {
+	t.Helper()
+	fixtures, err := omdbfake.DefaultFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, movie := range fixtures.Movies {
+		if strings.Contains(strings.ToLower(movie.Title), term) {
+			found = append(found, movie.Title)
+		}
+	}
+	return found
+}
+
+func TestUpstreamPages(t *testing.T) {
+	tests := []struct {
+		page, pageSize int
+		first, last    int
+	}{
+		{1, 10, 1, 1},
+		{2, 10, 2, 2},
+		{1, 25, 1, 3},
+		{2, 25, 3, 5},
+		{3, 5, 2, 2},
+		{2, 15, 2, 3},
+		{200, 10, 200, 100},
+	}
+	for _, tt := range tests {
+		first, last := upstreamPages(tt.page, tt.pageSize)
+		if first != tt.first || last != tt.last {
+			t.Errorf("upstreamPages(%d, %d) = %d, %d, want %d, %d", tt.page, tt.pageSize, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	stars := fixtureTitles(t, "star")
+	if len(stars) != 27 {
+		t.Fatalf("fixtures have %d titles with \"star\", want 27", len(stars))
+	}
+
+	tests := []struct {
+		name           string
+		page, pageSize int
+		want           []string
+		calls          int64
+	}{
+		// Pages 1 to 3 joined
+		{"whole upstream pages", 1, 25, stars[:25], 3},
+		// Pages 3 to 5, of which 4 and 5 are past the end, trimmed by 5
+		{"last partial page", 2, 25, stars[25:], 3},
+		// Page 2 alone, from its start
+		{"within one upstream page", 3, 5, stars[10:15], 1},
+		// Pages 2 and 3, trimmed by 5
+		{"straddling upstream pages", 2, 15, stars[15:], 2},
+		// Page 4 is past the end, so page 1 is asked for the total
+		{"past the last page", 7, 5, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOMDb(t)
+			s := newTestService(fake)
+
+			results, err := s.Search(context.Background(), SearchQuery{Query: "star", Page: tt.page, PageSize: tt.pageSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results.TotalResults != 27 {
+				t.Errorf("TotalResults = %d, want 27", results.TotalResults)
+			}
+
+			var got []string
+			for _, result := range results.Results {
+				got = append(got, result.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if n := fake.calls.Load(); n != tt.calls {
+				t.Errorf("OMDb got %d calls, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestSearchWithoutMatches(t *testing.T) {
+	fake := newFakeOMDb(t)
+	s := newTestService(fake)
+
+	for _, page := range []int{1, 3} {
+		results, err := s.Search(context.Background(), SearchQuery{Query: "no such movie", Page: page, PageSize: 10})
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if len(results.Results) != 0 || results.TotalResults != 0 {
+			t.Errorf("page %d: got %d results of %d, want none", page, len(results.Results), results.TotalResults)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"go-api/models"
+)
+
+// MetadataStore persists OMDb title and episode records by IMDb ID so they
+// survive restarts. Besides the records it remembers which IMDb ID each
+// normalized lookup was answered with, so title lookups can be served from
+// the store as well as ID lookups.
+type MetadataStore interface {
+	// Get returns the record stored for an IMDb ID
+	Get(imdbID string) (StoredRecord, bool)
+
+	// Resolve returns the IMDb ID that answered a lookup key
+	Resolve(key string) (string, bool)
+
+	// Put stores a record and, if key is non-empty, maps key to its IMDb ID
+	Put(key string, record StoredRecord) error
+
+	// Flush writes pending changes to durable storage
+	Flush() error
+
+	// Close flushes and releases the store
+	Close() error
+}
+
+// StoredRecord is an OMDb record together with the time it was fetched
+type StoredRecord struct {
+	Movie     models.OMDbResponse `json:"movie"`
+	FetchedAt time.Time           `json:"fetched_at"`
+}
+
+// FileStore is a MetadataStore kept in memory and written to a single JSON
+// file. Changes are flushed periodically and on Close; each flush replaces
+// the file atomically.
+type FileStore struct {
+	mu      sync.RWMutex
+	records map[string]StoredRecord
+	aliases map[string]string
+
+	flusher *fileFlusher
+}
+
+type fileStoreContents struct {
+	Records map[string]StoredRecord `json:"records"`
+	Aliases map[string]string       `json:"aliases"`
+}
+
+// OpenFileStore loads the store at path, creating it on first flush if it
+// does not exist yet. A positive flushInterval starts a background flusher.
+func OpenFileStore(path string, flushInterval time.Duration) (*FileStore, error) {
+	s := &FileStore{
+		records: make(map[string]StoredRecord),
+		aliases: make(map[string]string),
+	}
+	s.flusher = newFileFlusher(path, "metadata store", s.snapshot)
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read metadata store: %w", err)
+	default:
+		var contents fileStoreContents
+		if err := json.Unmarshal(data, &contents); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata store: %w", err)
+		}
+		if contents.Records != nil {
+			s.records = contents.Records
+		}
+		if contents.Aliases != nil {
+			s.aliases = contents.Aliases
+		}
+	}
+
+	s.flusher.start(flushInterval)
+	return s, nil
+}
+
+func (s *FileStore) Get(imdbID string) (StoredRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[imdbID]
+	return record, ok
+}
+
+func (s *FileStore) Resolve(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	imdbID, ok := s.aliases[key]
+	return imdbID, ok
+}
+
+func (s *FileStore) Put(key string, record StoredRecord) error {
+	imdbID := record.Movie.ImdbID
+	if imdbID == "" {
+		return errors.New("record has no IMDb ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[imdbID] = record
+	if key != "" {
+		s.aliases[key] = imdbID
+	}
+	s.flusher.markDirty()
+	return nil
+}
+
+// Len returns the number of stored records
+func (s *FileStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.records)
+}
+
+func (s *FileStore) Flush() error {
+	return s.flusher.flush()
+}
+
+func (s *FileStore) Close() error {
+	return s.flusher.close()
+}
+
+func (s *FileStore) snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return json.Marshal(fileStoreContents{Records: s.records, Aliases: s.aliases})
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the service's spans with whatever provider is installed;
+// without one, spans cost next to nothing
+var tracer = otel.Tracer("go-api/services")
+
+// endSpan marks span failed if err is set and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, outcome(err))
+	}
+	span.End()
+}